@@ -0,0 +1,139 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Command redash-types snapshots, diffs and validates against Redash data
+// source types:
+//
+//	redash-types snapshot <types.json>
+//	redash-types diff <old-types.json> <new-types.json>
+//	redash-types validate <types.json> <data-sources.json>
+//
+// snapshot reads REDASH_URL and REDASH_API_KEY from the environment. diff
+// exits with status 1 when the snapshots differ and validate when any data
+// source is invalid, so both can gate CI without a live Redash
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/snowplow-devops/redash-client-go/redash"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "snapshot":
+		err = snapshot(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	case "validate":
+		err = validate(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: redash-types snapshot <types.json>")
+	fmt.Fprintln(os.Stderr, "       redash-types diff <old-types.json> <new-types.json>")
+	fmt.Fprintln(os.Stderr, "       redash-types validate <types.json> <data-sources.json>")
+	os.Exit(2)
+}
+
+func snapshot(args []string) error {
+	if len(args) != 1 {
+		usage()
+	}
+
+	c, err := redash.NewClient(&redash.Config{
+		RedashURI: os.Getenv("REDASH_URL"),
+		APIKey:    os.Getenv("REDASH_API_KEY"),
+	})
+	if err != nil {
+		return fmt.Errorf("Error loading client: %q", err)
+	}
+
+	return c.SaveDataSourceTypesFile(args[0])
+}
+
+func diff(args []string) error {
+	if len(args) != 2 {
+		usage()
+	}
+
+	oldTypes, err := redash.LoadDataSourceTypesFile(args[0])
+	if err != nil {
+		return err
+	}
+	newTypes, err := redash.LoadDataSourceTypesFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	changes := redash.DiffDataSourceTypes(oldTypes, newTypes)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func validate(args []string) error {
+	if len(args) != 2 {
+		usage()
+	}
+
+	dataSourceTypes, err := redash.LoadDataSourceTypesFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+	dataSources := []redash.DataSource{}
+	err = json.Unmarshal(body, &dataSources)
+	if err != nil {
+		return err
+	}
+
+	invalid := 0
+	for i := range dataSources {
+		_, err := redash.ValidateDataSourceOptions(&dataSources[i], dataSourceTypes, true)
+		if err != nil {
+			fmt.Printf("%s: %v\n", dataSources[i].Name, err)
+			invalid++
+		}
+	}
+
+	if invalid > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -27,7 +27,11 @@ func main() {
 	hostname := os.Getenv("REDASH_URL")
 
 	log.SetLevel(log.DebugLevel)
-	c, err := redash.NewClient(&redash.Config{RedashURI: hostname, APIKey: apiKey})
+	c, err := redash.NewClient(&redash.Config{
+		RedashURI: hostname,
+		APIKey:    apiKey,
+		Logger:    redash.NewLogrusLogger(log.StandardLogger()),
+	})
 	if err != nil {
 		log.Fatal(fmt.Errorf("Error loading client: %q", err))
 		return
@@ -1,11 +1,12 @@
 module github.com/snowplow-devops/redash-client-go
 
-go 1.17
+go 1.21
 
 require (
 	github.com/jarcoal/httpmock v1.0.6
 	github.com/sirupsen/logrus v1.9.0
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/time v0.5.0
 )
 
 require (
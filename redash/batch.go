@@ -0,0 +1,260 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultBatchConcurrency is how many queries of a batch run at once when
+// no limit is given
+const defaultBatchConcurrency = 4
+
+// BatchQuery is a saved Query to run as part of a batch, with its parameter
+// values
+type BatchQuery struct {
+	QueryID    QueryID
+	Parameters QueryParameters
+}
+
+// BatchResult is the outcome of a BatchQuery: its result, or the error it
+// failed with
+type BatchResult struct {
+	BatchQuery
+	Result   *QueryResult
+	Err      error
+	Duration time.Duration
+}
+
+// BatchReport holds the outcome of every query of a batch, in the order the
+// queries were given
+type BatchReport struct {
+	Results []BatchResult
+}
+
+// Succeeded returns the outcomes of the queries which produced a result
+func (r *BatchReport) Succeeded() []BatchResult {
+	succeeded := []BatchResult{}
+	for _, result := range r.Results {
+		if result.Err == nil {
+			succeeded = append(succeeded, result)
+		}
+	}
+
+	return succeeded
+}
+
+// Failed returns the outcomes of the queries which failed
+func (r *BatchReport) Failed() []BatchResult {
+	failed := []BatchResult{}
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// Err joins the errors of the queries which failed, and is nil when all
+// of them succeeded
+func (r *BatchReport) Err() error {
+	errs := []error{}
+	for _, result := range r.Failed() {
+		errs = append(errs, result.Err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// RunQueries runs many saved queries as RunQuery does, with at most
+// maxConcurrency of them running at once, or four when it is zero. The jobs
+// of the running queries are polled from a single loop rather than one per
+// query, and requests respect Config.RateLimit. A query failing does not
+// stop the others
+func (c *Client) RunQueries(queries []BatchQuery, maxConcurrency int) *BatchReport {
+	return c.RunQueriesContext(context.Background(), queries, maxConcurrency)
+}
+
+// RunQueriesContext is RunQueries bound to the given context. Cancelling it
+// stops the batch, the queries not run yet failing with its error
+func (c *Client) RunQueriesContext(ctx context.Context, queries []BatchQuery, maxConcurrency int) *BatchReport {
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultBatchConcurrency
+	}
+
+	report := &BatchReport{Results: make([]BatchResult, len(queries))}
+
+	pollCtx, stopPolling := context.WithCancel(ctx)
+	defer stopPolling()
+	poller := newJobPoller(c)
+	go poller.run(pollCtx)
+
+	slots := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		report.Results[i].BatchQuery = query
+
+		err := ctx.Err()
+		if err == nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		if err != nil {
+			report.Results[i].Err = fmt.Errorf("Query %d was not run: %w", query.QueryID, err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(outcome *BatchResult) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			start := time.Now()
+			outcome.Result, outcome.Err = c.runQuery(ctx, outcome.QueryID, outcome.Parameters, poller.wait)
+			outcome.Duration = time.Since(start)
+
+			c.logger().Debug("Batch query finished",
+				Field{"query_id", outcome.QueryID},
+				Field{"duration", outcome.Duration},
+				Field{"failed", outcome.Err != nil})
+		}(&report.Results[i])
+	}
+	wg.Wait()
+
+	return report
+}
+
+// jobPoller polls the jobs many goroutines wait for from a single loop.
+// Redash gives identical runs the same job, so a job may have several waiters
+type jobPoller struct {
+	c *Client
+
+	mu      sync.Mutex
+	waiting map[JobID][]chan jobUpdate
+	// over holds the jobs seen over or cancelled, so each is cancelled at
+	// most once when waiters of the same job give up one after the other
+	over map[JobID]bool
+}
+
+type jobUpdate struct {
+	job *Job
+	err error
+}
+
+func newJobPoller(c *Client) *jobPoller {
+	return &jobPoller{c: c, waiting: map[JobID][]chan jobUpdate{}, over: map[JobID]bool{}}
+}
+
+// wait blocks until the polling loop sees the job over, as waitForJob does.
+// The job is cancelled when the context ends the wait and no one else waits
+// for it. A job which failed, or whose polling failed, is left as it is
+func (p *jobPoller) wait(ctx context.Context, id JobID) (*Job, error) {
+	updates := make(chan jobUpdate, 1)
+	p.mu.Lock()
+	p.waiting[id] = append(p.waiting[id], updates)
+	p.mu.Unlock()
+
+	var update jobUpdate
+	abandoned := false
+	select {
+	case update = <-updates:
+	case <-ctx.Done():
+		abandoned = true
+		update.err = fmt.Errorf("Job %s did not finish: %w", id, ctx.Err())
+	}
+
+	if p.release(id, updates, abandoned) {
+		p.c.abandonJob(ctx, id)
+	}
+
+	return update.job, update.err
+}
+
+// release stops sending updates of the job to a waiter, reporting whether
+// the job is to be cancelled: the waiter abandoned it, no one else waits for
+// it and it was neither seen over nor cancelled already
+func (p *jobPoller) release(id JobID, updates chan jobUpdate, abandoned bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	waiters := p.waiting[id]
+	for i, waiter := range waiters {
+		if waiter == updates {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) > 0 {
+		p.waiting[id] = waiters
+		return false
+	}
+
+	delete(p.waiting, id)
+	if !abandoned || p.over[id] {
+		return false
+	}
+	p.over[id] = true
+	return true
+}
+
+// run polls every waited job once per poll interval until the context is done
+func (p *jobPoller) run(ctx context.Context) {
+	ticker := time.NewTicker(p.c.jobPollInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		ids := make([]JobID, 0, len(p.waiting))
+		for id := range p.waiting {
+			ids = append(ids, id)
+		}
+		p.mu.Unlock()
+
+		for _, id := range ids {
+			job, err := p.c.GetJobContext(ctx, id)
+			if err != nil && ctx.Err() != nil {
+				// the waiters see their own context end
+				return
+			}
+			if err == nil {
+				var done bool
+				if done, err = job.outcome(); !done {
+					continue
+				}
+			}
+
+			p.mu.Lock()
+			for _, updates := range p.waiting[id] {
+				updates <- jobUpdate{job: job, err: err}
+			}
+			delete(p.waiting, id)
+			p.over[id] = true
+			p.mu.Unlock()
+		}
+	}
+}
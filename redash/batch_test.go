@@ -0,0 +1,208 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchResponders answers for queries 1 to count, each running as job
+// "job-N" which finishes on its second poll with result N, except query 2
+// whose job fails. It returns the most queries seen running at once
+func batchResponders(count int) func() int {
+	var mu sync.Mutex
+	running, mostRunning := 0, 0
+	polls := map[string]int{}
+
+	for id := 1; id <= count; id++ {
+		httpmock.RegisterResponder("GET", fmt.Sprintf("https://com.acme/api/queries/%d", id),
+			httpmock.NewStringResponder(200, fmt.Sprintf(`{"id": %d, "options": {}}`, id)))
+		httpmock.RegisterResponder("POST", fmt.Sprintf("https://com.acme/api/queries/%d/results", id),
+			func(id int) httpmock.Responder {
+				return func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					running++
+					if running > mostRunning {
+						mostRunning = running
+					}
+					return httpmock.NewStringResponse(200, fmt.Sprintf(`{"job": {"id": "job-%d", "status": 1}}`, id)), nil
+				}
+			}(id))
+		httpmock.RegisterResponder("GET", fmt.Sprintf("https://com.acme/api/query_results/%d", id),
+			func(id int) httpmock.Responder {
+				return func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					running--
+					return httpmock.NewStringResponse(200, fmt.Sprintf(`{"query_result": {"id": %d, "data": {"columns": [], "rows": []}}}`, id)), nil
+				}
+			}(id))
+	}
+
+	httpmock.RegisterResponder("GET", `=~^https://com\.acme/api/jobs/job-(\d+)\z`,
+		func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			id := strings.TrimPrefix(req.URL.Path, "/api/jobs/")
+			polls[id]++
+			switch {
+			case polls[id] < 2:
+				return httpmock.NewStringResponse(200, fmt.Sprintf(`{"job": {"id": "%s", "status": 2}}`, id)), nil
+			case id == "job-2":
+				running--
+				return httpmock.NewStringResponse(200, `{"job": {"id": "job-2", "status": 4, "error": "division by zero"}}`), nil
+			}
+			return httpmock.NewStringResponse(200, fmt.Sprintf(`{"job": {"id": "%s", "status": 3, "query_result_id": %s}}`, id, strings.TrimPrefix(id, "job-"))), nil
+		})
+
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return mostRunning
+	}
+}
+
+func TestRunQueries(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	mostRunning := batchResponders(6)
+
+	queries := []BatchQuery{}
+	for id := 1; id <= 6; id++ {
+		queries = append(queries, BatchQuery{QueryID: QueryID(id)})
+	}
+
+	report := c.RunQueries(queries, 2)
+	assert.Len(report.Results, 6)
+	for i, result := range report.Results {
+		assert.Equal(QueryID(i+1), result.QueryID)
+		if i == 1 {
+			assert.Nil(result.Result)
+			continue
+		}
+		assert.Nil(result.Err)
+		assert.Equal(QueryResultID(i+1), result.Result.ID)
+	}
+
+	assert.Len(report.Succeeded(), 5)
+	assert.Len(report.Failed(), 1)
+	assert.EqualError(report.Err(), "Error running query 2: Job job-2 failed: division by zero")
+	assert.LessOrEqual(mostRunning(), 2)
+	assert.Equal(12, httpmock.GetCallCountInfo()[`GET =~^https://com\.acme/api/jobs/job-(\d+)\z`])
+}
+
+func TestRunQueriesSharedJob(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	batchResponders(1)
+
+	// identical runs get the same job from Redash
+	report := c.RunQueries([]BatchQuery{{QueryID: 1}, {QueryID: 1}, {QueryID: 1}}, 3)
+	assert.Nil(report.Err())
+	for _, result := range report.Results {
+		assert.Equal(QueryResultID(1), result.Result.ID)
+	}
+}
+
+func TestRunQueriesCancelled(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	batchResponders(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := c.RunQueriesContext(ctx, []BatchQuery{{QueryID: 1}, {QueryID: 2}}, 1)
+	assert.Len(report.Failed(), 2)
+	assert.True(errors.Is(report.Err(), context.Canceled))
+	assert.Equal(0, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/1/results"])
+}
+
+// expiredContext reports an error without ever being done, as a context
+// expiring right after a wait ended would
+type expiredContext struct {
+	context.Context
+}
+
+func (expiredContext) Err() error {
+	return context.Canceled
+}
+
+func TestJobPollerLeavesFailedJobs(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 4, "error": "boom"}}`))
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, ``))
+
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	defer stopPolling()
+	poller := newJobPoller(c)
+	go poller.run(pollCtx)
+
+	// the job failing ended the wait, not the context
+	_, err := poller.wait(expiredContext{context.Background()}, "77ab")
+	assert.EqualError(err, "Job 77ab failed: boom")
+	assert.Equal(0, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/jobs/77ab"])
+}
+
+func TestJobPollerCancelsJobsOnce(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, ``))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the second waiter of the job only comes after the first gave up
+	poller := newJobPoller(c)
+	_, err := poller.wait(ctx, "77ab")
+	assert.EqualError(err, "Job 77ab did not finish: context canceled")
+	_, err = poller.wait(ctx, "77ab")
+	assert.EqualError(err, "Job 77ab did not finish: context canceled")
+	assert.Equal(1, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/jobs/77ab"])
+}
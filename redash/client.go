@@ -15,24 +15,79 @@
 package redash
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
-	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrNotFound is wrapped by the errors of lookups which match nothing
+	ErrNotFound = errors.New("Not found")
+	// ErrAmbiguous is wrapped by the errors of lookups which match more than
+	// one resource
+	ErrAmbiguous = errors.New("Ambiguous match")
 )
 
 // Client contains an active Redash API client
 type Client struct {
 	Config *Config
+
+	mu          sync.RWMutex
+	middlewares []Middleware
+	transport   http.RoundTripper
+	tail        *transportLink
+	limiter     *rate.Limiter
+	secrets     *secretRegistry
 }
 
 // Config holds the necessary setup vars
 type Config struct {
-	RedashURI  string
-	APIKey     string
+	// RedashURI is the base URI of the Redash instance
+	RedashURI string
+	// APIKey is the API key of the user the client acts as
+	APIKey string
+	// StrictMode makes data source creates and updates fail when their
+	// payload holds extraneous properties
 	StrictMode bool
+	// PreserveSecrets makes data source updates omit secret options which
+	// still hold the mask Redash returns in place of their real value
+	PreserveSecrets bool
+	// StrictDecoding makes reads of users, groups and data sources fail
+	// when Redash sends fields the client does not declare
+	StrictDecoding bool
+	// DataSourceTypes, when set, are used instead of fetching the types
+	// from Redash
+	DataSourceTypes []DataSourceType
+	// JobPollInterval is how often background jobs are polled, one second
+	// when zero
+	JobPollInterval time.Duration
+	// JobTimeout bounds the wait for a background job, five minutes when
+	// zero
+	JobTimeout time.Duration
+	// Logger receives the client's log messages, which are discarded when
+	// it is nil
+	Logger Logger
+	// Tracer, when set, receives a span for every API call
+	Tracer Tracer
+	// Meter, when set, records the duration and errors of every API call
+	Meter Meter
+	// MaxAge is the age of the oldest result a query run may be answered
+	// with instead of running the query. With zero, Redash always runs the
+	// query, while the ResultCache answers with any result it holds
+	MaxAge time.Duration
+	// ResultCache, when set, keeps the results of saved queries run by
+	// RunQuery on the client side until its TTL expires them
+	ResultCache ResultCache
+	// RateLimit is the most requests per second the client sends, requests
+	// waiting their turn, and zero leaves them unlimited
+	RateLimit float64
 }
 
 // NewClient returns a *Client from a valid *Config
@@ -50,7 +105,12 @@ func NewClient(config *Config) (*Client, error) {
 		return nil, fmt.Errorf("Missing APIKey")
 	}
 
-	c := &Client{Config: config}
+	c := &Client{Config: config, secrets: newSecretRegistry()}
+	c.RegisterDataSourceSecrets(config.DataSourceTypes)
+	if config.RateLimit > 0 {
+		c.limiter = rate.NewLimiter(rate.Limit(config.RateLimit), 1)
+	}
+
 	return c, nil
 }
 
@@ -61,13 +121,30 @@ func (c *Client) IsStrict() bool {
 	return c.Config.StrictMode
 }
 
-func (c *Client) doRequest(method, path, body string, query url.Values) (*http.Response, error) {
+// PreservesSecrets returns true if PreserveSecrets is set. This causes
+// data_source updates to omit secret options that still hold the mask
+// Redash returns in place of their real value.
+func (c *Client) PreservesSecrets() bool {
+	return c.Config.PreserveSecrets
+}
+
+// doRequestContext sends a request through the middleware chain, bound to
+// the given context
+func (c *Client) doRequestContext(ctx context.Context, method, path, body string, query url.Values) (*http.Response, error) {
 	requestURI := strings.TrimSuffix(c.Config.RedashURI, "/") + path
 
-	log.Debug(fmt.Sprintf("[DEBUG] %s request to %s", method, path))
+	ctx, call := c.startAPICall(ctx, method, path)
 
+	start := time.Now()
+	status := 0
 	response, err := func() (*http.Response, error) {
-		request, err := http.NewRequest(method, requestURI, strings.NewReader(body))
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		request, err := http.NewRequestWithContext(ctx, method, requestURI, strings.NewReader(body))
 		if err != nil {
 			return nil, err
 		}
@@ -76,31 +153,65 @@ func (c *Client) doRequest(method, path, body string, query url.Values) (*http.R
 		request.Header.Set("Authorization", "Key "+c.Config.APIKey)
 		request.URL.RawQuery = query.Encode()
 
-		return http.DefaultClient.Do(request)
+		if err := c.beforeRequest(request); err != nil {
+			return nil, err
+		}
+
+		response, err := c.httpClient().Do(request)
+		if err != nil {
+			return nil, err
+		}
+		status = response.StatusCode
+
+		if err := c.afterResponse(response); err != nil {
+			response.Body.Close()
+			return nil, err
+		}
+
+		return response, nil
 	}()
 	if err != nil {
+		c.logger().Debug("Redash request failed",
+			Field{"method", method},
+			Field{"path", path},
+			Field{"duration", time.Since(start)},
+			Field{"error", err.Error()})
+		call.end(ctx, status, err)
 		return nil, err
 	}
 
+	fields := []Field{
+		{"method", method},
+		{"path", path},
+		{"status", response.StatusCode},
+		{"duration", time.Since(start)},
+	}
+	if body != "" {
+		fields = append(fields, Field{"body", c.secrets.redactBody(body)})
+	}
+	c.logger().Debug("Redash request", fields...)
+
 	if response.StatusCode < 200 || response.StatusCode > 299 {
-		return nil, fmt.Errorf("HTTP Response: %d", response.StatusCode)
+		response.Body.Close()
+		err := fmt.Errorf("HTTP Response: %d", response.StatusCode)
+		call.end(ctx, response.StatusCode, err)
+		return nil, err
 	}
 
+	response.Body = &callBody{ReadCloser: response.Body, end: func() {
+		call.end(ctx, status, nil)
+	}}
 	return response, nil
 }
 
-func (c *Client) get(path string, query url.Values) (*http.Response, error) {
-	return c.doRequest(http.MethodGet, path, "", query)
-}
-
-func (c *Client) post(path string, payload string, query url.Values) (*http.Response, error) {
-	return c.doRequest(http.MethodPost, path, payload, query)
+func (c *Client) getContext(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	return c.doRequestContext(ctx, http.MethodGet, path, "", query)
 }
 
-func (c *Client) put(path string, payload string, query url.Values) (*http.Response, error) {
-	return c.doRequest(http.MethodPut, path, payload, query)
+func (c *Client) postContext(ctx context.Context, path string, payload string, query url.Values) (*http.Response, error) {
+	return c.doRequestContext(ctx, http.MethodPost, path, payload, query)
 }
 
-func (c *Client) delete(path string, query url.Values) (*http.Response, error) {
-	return c.doRequest(http.MethodDelete, path, "", query)
+func (c *Client) deleteContext(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	return c.doRequestContext(ctx, http.MethodDelete, path, "", query)
 }
@@ -14,11 +14,36 @@
 package redash
 
 import (
+	"context"
+	"net/http"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 )
 
+// sequenceResponder answers with each body in turn, repeating the last one
+// once all have been used
+func sequenceResponder(bodies ...string) httpmock.Responder {
+	var mu sync.Mutex
+	calls := 0
+
+	return func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body := bodies[len(bodies)-1]
+		if calls < len(bodies) {
+			body = bodies[calls]
+		}
+		calls++
+
+		return httpmock.NewStringResponse(200, body), nil
+	}
+}
+
 func TestNewClient(t *testing.T) {
 	assert := assert.New(t)
 
@@ -46,3 +71,27 @@ func TestNewClient(t *testing.T) {
 	assert.Nil(err)
 	assert.NotNil(c)
 }
+
+func TestRateLimit(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", RateLimit: 20})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, `{"id": 3}`))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := c.GetQuery(3)
+		assert.Nil(err)
+	}
+	assert.GreaterOrEqual(time.Since(start), 90*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.GetQueryContext(ctx, 3)
+	assert.NotNil(err)
+	assert.Equal(3, httpmock.GetTotalCallCount())
+}
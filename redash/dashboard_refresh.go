@@ -0,0 +1,229 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WidgetRefresh is the outcome of refreshing a widget of a dashboard: the
+// new result of its query, or the error it failed with
+type WidgetRefresh struct {
+	WidgetID        WidgetID
+	VisualizationID VisualizationID
+	QueryID         QueryID
+	Result          *QueryResult
+	Err             error
+}
+
+// DashboardRefreshReport holds the outcome of refreshing every widget of a
+// dashboard showing a query, in the order of the dashboard's widgets
+type DashboardRefreshReport struct {
+	DashboardID DashboardID
+	Widgets     []WidgetRefresh
+}
+
+// Failed returns the outcomes of the widgets which failed to refresh
+func (r *DashboardRefreshReport) Failed() []WidgetRefresh {
+	failed := []WidgetRefresh{}
+	for _, widget := range r.Widgets {
+		if widget.Err != nil {
+			failed = append(failed, widget)
+		}
+	}
+
+	return failed
+}
+
+// Err joins the errors of the widgets which failed to refresh, and is nil
+// when all of them were refreshed
+func (r *DashboardRefreshReport) Err() error {
+	errs := []error{}
+	for _, widget := range r.Failed() {
+		errs = append(errs, fmt.Errorf("Widget %d: %w", widget.WidgetID, widget.Err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// staticValue is the value of a parameter set by a static-value mapping,
+// which Redash keeps already encoded
+type staticValue struct {
+	value interface{}
+}
+
+func (v staticValue) encode(def *QueryParameterDefinition) (interface{}, error) {
+	if v.value == nil {
+		return def.Value, nil
+	}
+
+	return v.value, nil
+}
+
+// RefreshDashboard runs the queries of every visualization widget of a
+// Dashboard and waits for their results, as RunQueries does. Parameters
+// hold the values of dashboard-level parameters, which widgets map to the
+// parameters of their queries; widget-level parameters take their default
+// value. Widgets showing the same query with the same values share a run.
+//
+// The queries run regardless of the results cached by Redash or the
+// client, unless RefreshDashboardContext is given a context setting a max
+// age with ContextWithMaxAge, and
+// replace their results in the client's ResultCache. The error is only set
+// when the dashboard could not be read or a parameter is not one of the
+// dashboard's; widgets failing to refresh are listed by the report
+func (c *Client) RefreshDashboard(id DashboardID, parameters QueryParameters) (*DashboardRefreshReport, error) {
+	return c.RefreshDashboardContext(context.Background(), id, parameters)
+}
+
+// RefreshDashboardContext is RefreshDashboard bound to the given context
+func (c *Client) RefreshDashboardContext(ctx context.Context, id DashboardID, parameters QueryParameters) (*DashboardRefreshReport, error) {
+	dashboard, err := c.GetDashboardContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DashboardRefreshReport{DashboardID: id}
+	queries := []BatchQuery{}
+	runs := map[ResultCacheKey]int{}
+	widgetRuns := []int{}
+	known := map[string]bool{}
+	read := map[QueryID]*Query{}
+
+	for i := range dashboard.Widgets {
+		widget := &dashboard.Widgets[i]
+		if widget.Visualization == nil || widget.Visualization.Query == nil {
+			continue
+		}
+
+		refresh := WidgetRefresh{
+			WidgetID:        widget.ID,
+			VisualizationID: widget.Visualization.ID,
+			QueryID:         widget.Visualization.Query.ID,
+		}
+
+		values, err := widgetParameters(widget, parameters, known)
+		var key ResultCacheKey
+		if err == nil {
+			key, err = c.widgetRunKey(ctx, widget, values, read)
+		}
+		if err != nil {
+			refresh.Err = err
+			report.Widgets = append(report.Widgets, refresh)
+			widgetRuns = append(widgetRuns, -1)
+			continue
+		}
+
+		run, ok := runs[key]
+		if !ok {
+			run = len(queries)
+			runs[key] = run
+			queries = append(queries, BatchQuery{QueryID: refresh.QueryID, Parameters: values})
+		}
+
+		report.Widgets = append(report.Widgets, refresh)
+		widgetRuns = append(widgetRuns, run)
+	}
+
+	unknown := []string{}
+	for name := range parameters {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("Unknown parameters for dashboard %d: %s", id, strings.Join(unknown, ", "))
+	}
+
+	if _, ok := ctx.Value(maxAgeKey{}).(time.Duration); !ok {
+		ctx = ContextWithMaxAge(ctx, 0)
+	}
+
+	batch := c.RunQueriesContext(ctx, queries, 0)
+	for i, run := range widgetRuns {
+		if run < 0 {
+			continue
+		}
+		report.Widgets[i].Result = batch.Results[run].Result
+		report.Widgets[i].Err = batch.Results[run].Err
+	}
+
+	return report, nil
+}
+
+// widgetRunKey returns the key of the run of a widget's query with the
+// given values, encoded against the query's parameter definitions as
+// RunQuery encodes them, so equal runs get equal keys. Queries which the
+// dashboard lists without their options are read from Redash once
+func (c *Client) widgetRunKey(ctx context.Context, widget *Widget, values QueryParameters, read map[QueryID]*Query) (ResultCacheKey, error) {
+	query := widget.Visualization.Query
+	if query.Options == nil {
+		if read[query.ID] == nil {
+			fetched, err := c.GetQueryContext(ctx, query.ID)
+			if err != nil {
+				return ResultCacheKey{}, err
+			}
+			read[query.ID] = fetched
+		}
+		query = read[query.ID]
+	}
+
+	definitions, err := query.Parameters()
+	if err != nil {
+		return ResultCacheKey{}, err
+	}
+
+	encoded, err := ValidateQueryParameters(definitions, values)
+	if err != nil {
+		return ResultCacheKey{}, err
+	}
+
+	return newResultCacheKey(widget.Visualization.Query.ID, query.Version, encoded)
+}
+
+// widgetParameters returns the values of the parameters of a widget's query
+// given the dashboard's, recording in known the dashboard parameters the
+// widget maps
+func widgetParameters(widget *Widget, parameters QueryParameters, known map[string]bool) (QueryParameters, error) {
+	mappings, err := widget.ParameterMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	values := QueryParameters{}
+	for name, mapping := range mappings {
+		switch mapping.Type {
+		case MappingDashboardLevel:
+			mapTo := mapping.MapTo
+			if mapTo == "" {
+				mapTo = name
+			}
+			known[mapTo] = true
+
+			if value, ok := parameters[mapTo]; ok {
+				values[name] = value
+			}
+		case MappingStaticValue:
+			values[name] = staticValue{value: mapping.Value}
+		}
+	}
+
+	return values, nil
+}
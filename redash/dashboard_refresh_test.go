@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// exportDashboard shows the export query twice with the same mappings, a
+// query whose run fails and a widget with broken parameter mappings
+const exportDashboard = `{
+	"id": 2,
+	"name": "Exports",
+	"slug": "exports",
+	"widgets": [
+		{"id": 1, "text": "## Exports"},
+		{"id": 2, "options": {"parameterMappings": {
+			"channel": {"name": "channel", "type": "dashboard-level", "mapTo": "source", "value": null, "title": ""},
+			"period": {"name": "period", "type": "static-value", "mapTo": "period", "value": "d_last_30_days", "title": ""}
+		}}, "visualization": {"id": 20, "type": "TABLE", "query": {"id": 3}}},
+		{"id": 3, "options": {"parameterMappings": {
+			"channel": {"name": "channel", "type": "dashboard-level", "mapTo": "source", "value": null, "title": ""},
+			"period": {"name": "period", "type": "static-value", "mapTo": "period", "value": "d_last_30_days", "title": ""}
+		}}, "visualization": {"id": 21, "type": "CHART", "query": {"id": 3}}},
+		{"id": 4, "options": {"parameterMappings": {
+			"region": {"name": "region", "type": "widget-level", "mapTo": "region", "value": null, "title": ""}
+		}}, "visualization": {"id": 22, "type": "CHART", "query": {"id": 7}}},
+		{"id": 5, "options": {"parameterMappings": "channel"}, "visualization": {"id": 23, "type": "TABLE", "query": {"id": 8}}}
+	]
+}`
+
+func TestWidgetParameterMappings(t *testing.T) {
+	assert := assert.New(t)
+
+	widget := Widget{ID: 2, Options: map[string]interface{}{
+		"parameterMappings": map[string]interface{}{
+			"channel": map[string]interface{}{"name": "channel", "type": "dashboard-level", "mapTo": "source"},
+		},
+	}}
+	mappings, err := widget.ParameterMappings()
+	assert.Nil(err)
+	assert.Equal(map[string]ParameterMapping{
+		"channel": {Name: "channel", Type: MappingDashboardLevel, MapTo: "source"},
+	}, mappings)
+
+	mappings, err = (&Widget{ID: 3}).ParameterMappings()
+	assert.Nil(err)
+	assert.Empty(mappings)
+
+	_, err = (&Widget{ID: 4, Options: map[string]interface{}{"parameterMappings": "channel"}}).ParameterMappings()
+	assert.EqualError(err, "Invalid parameter mappings for widget 4: json: cannot unmarshal string into Go value of type map[string]redash.ParameterMapping")
+}
+
+func TestRefreshDashboard(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{
+		RedashURI:       "https://com.acme/",
+		APIKey:          "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		JobPollInterval: time.Millisecond,
+		MaxAge:          time.Hour,
+		ResultCache:     NewMemoryResultCache(10, 0),
+	})
+
+	var sent map[string]interface{}
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards/2",
+		httpmock.NewStringResponder(200, exportDashboard))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		captureExecution(&sent, exportResult))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/7",
+		httpmock.NewStringResponder(200, `{"id": 7, "options": {"parameters": [{"name": "region", "type": "text", "value": "emea"}]}}`))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/7/results",
+		httpmock.NewStringResponder(200, `{"job": {"id": "99ef", "status": 1}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/99ef",
+		httpmock.NewStringResponder(200, `{"job": {"id": "99ef", "status": 4, "error": "timeout"}}`))
+
+	report, err := c.RefreshDashboard(2, QueryParameters{"source": Enum("mobile")})
+	assert.Nil(err)
+	assert.Equal(DashboardID(2), report.DashboardID)
+	assert.Len(report.Widgets, 4)
+
+	assert.Equal(map[string]interface{}{
+		"parameters": map[string]interface{}{"channel": "mobile", "period": "d_last_30_days"},
+		"max_age":    float64(0),
+	}, sent)
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+
+	for _, widget := range report.Widgets[:2] {
+		assert.Nil(widget.Err)
+		assert.Equal(QueryID(3), widget.QueryID)
+		assert.Equal(QueryResultID(9), widget.Result.ID)
+	}
+	assert.Equal(VisualizationID(21), report.Widgets[1].VisualizationID)
+
+	assert.Len(report.Failed(), 2)
+	assert.Equal(WidgetID(4), report.Widgets[2].WidgetID)
+	assert.EqualError(report.Widgets[2].Err, "Error running query 7: Job 99ef failed: timeout")
+	assert.Equal(WidgetID(5), report.Widgets[3].WidgetID)
+	assert.Equal(0, httpmock.GetCallCountInfo()["GET https://com.acme/api/queries/8"])
+	assert.Contains(report.Err().Error(), "Widget 4: Error running query 7: Job 99ef failed: timeout\nWidget 5: Invalid parameter mappings for widget 5")
+
+	// a refresh runs the queries again rather than reusing cached results
+	_, err = c.RefreshDashboard(2, nil)
+	assert.Nil(err)
+	assert.Equal(2, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+	assert.Equal("web", sent["parameters"].(map[string]interface{})["channel"])
+
+	_, err = c.RefreshDashboard(2, QueryParameters{"region": TextValue("apac"), "channel": Enum("web")})
+	assert.EqualError(err, "Unknown parameters for dashboard 2: channel, region")
+	assert.Equal(2, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+}
+
+func TestRefreshDashboardSharesRuns(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	// the widgets set the channel differently, but to the same encoded value
+	query := strings.TrimSuffix(strings.TrimPrefix(exportQuery, "{"), "}")
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards/2",
+		httpmock.NewStringResponder(200, `{"id": 2, "widgets": [
+			{"id": 2, "options": {"parameterMappings": {
+				"channel": {"name": "channel", "type": "dashboard-level", "mapTo": "source"}
+			}}, "visualization": {"id": 20, "query": {`+query+`}}},
+			{"id": 3, "options": {"parameterMappings": {
+				"channel": {"name": "channel", "type": "static-value", "value": "mobile"}
+			}}, "visualization": {"id": 21, "query": {`+query+`}}}
+		]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		httpmock.NewStringResponder(200, exportResult))
+
+	report, err := c.RefreshDashboard(2, QueryParameters{"source": Enum("mobile")})
+	assert.Nil(err)
+	assert.Nil(report.Err())
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+	// the dashboard holds the parameter definitions, only the run reads the query
+	assert.Equal(1, httpmock.GetCallCountInfo()["GET https://com.acme/api/queries/3"])
+	assert.Same(report.Widgets[0].Result, report.Widgets[1].Result)
+}
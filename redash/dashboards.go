@@ -0,0 +1,215 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DashboardList struct
+type DashboardList struct {
+	Count    int         `json:"count"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+	Results  []Dashboard `json:"results,omitempty"`
+}
+
+// Dashboard struct. Widgets are only returned by GetDashboard
+type Dashboard struct {
+	ID         DashboardID `json:"id,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	Slug       string      `json:"slug,omitempty"`
+	Tags       []string    `json:"tags,omitempty"`
+	IsArchived bool        `json:"is_archived,omitempty"`
+	IsDraft    bool        `json:"is_draft,omitempty"`
+	Widgets    []Widget    `json:"widgets,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	CreatedAt  *time.Time  `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time  `json:"updated_at,omitempty"`
+}
+
+// Widget struct. Text widgets have no Visualization
+type Widget struct {
+	ID            WidgetID               `json:"id,omitempty"`
+	DashboardID   DashboardID            `json:"dashboard_id,omitempty"`
+	Text          string                 `json:"text,omitempty"`
+	Width         int                    `json:"width,omitempty"`
+	Options       map[string]interface{} `json:"options,omitempty"`
+	Visualization *Visualization         `json:"visualization,omitempty"`
+}
+
+// ParameterMappingType is where a widget takes the value of a parameter of
+// its query from
+type ParameterMappingType string
+
+// Parameter mapping types supported by Redash
+const (
+	MappingDashboardLevel ParameterMappingType = "dashboard-level"
+	MappingWidgetLevel    ParameterMappingType = "widget-level"
+	MappingStaticValue    ParameterMappingType = "static-value"
+)
+
+// ParameterMapping struct, as found in a Widget's options. MapTo names the
+// dashboard parameter of dashboard-level mappings, and Value holds the value
+// of static ones
+type ParameterMapping struct {
+	Name  string               `json:"name"`
+	Type  ParameterMappingType `json:"type"`
+	MapTo string               `json:"mapTo,omitempty"`
+	Value interface{}          `json:"value,omitempty"`
+	Title string               `json:"title,omitempty"`
+}
+
+// ParameterMappings returns how the widget sets the parameters of its query,
+// keyed by parameter name
+func (w *Widget) ParameterMappings() (map[string]ParameterMapping, error) {
+	raw, ok := w.Options["parameterMappings"]
+	if !ok {
+		return map[string]ParameterMapping{}, nil
+	}
+
+	payload, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := map[string]ParameterMapping{}
+	err = json.Unmarshal(payload, &mappings)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid parameter mappings for widget %d: %v", w.ID, err)
+	}
+
+	return mappings, nil
+}
+
+// Visualization struct
+type Visualization struct {
+	ID          VisualizationID        `json:"id,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Options     map[string]interface{} `json:"options,omitempty"`
+	Query       *Query                 `json:"query,omitempty"`
+}
+
+// GetDashboards returns a paginated list of dashboards, archived ones excluded
+func (c *Client) GetDashboards(page, pageSize int) (*DashboardList, error) {
+	return c.GetDashboardsContext(context.Background(), page, pageSize)
+}
+
+// GetDashboardsContext is GetDashboards bound to the given context
+func (c *Client) GetDashboardsContext(ctx context.Context, page, pageSize int) (*DashboardList, error) {
+	path := "/api/dashboards"
+
+	query := url.Values{}
+	query.Add("page", strconv.Itoa(page))
+	query.Add("page_size", strconv.Itoa(pageSize))
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboards := DashboardList{}
+	err = json.Unmarshal(body, &dashboards)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dashboards, nil
+}
+
+// GetAllDashboards returns every dashboard, reading all pages of GetDashboards
+func (c *Client) GetAllDashboards() ([]Dashboard, error) {
+	return c.GetAllDashboardsContext(context.Background())
+}
+
+// GetAllDashboardsContext is GetAllDashboards bound to the given context
+func (c *Client) GetAllDashboardsContext(ctx context.Context) ([]Dashboard, error) {
+	dashboards := []Dashboard{}
+
+	for page := 1; ; page++ {
+		dashboardList, err := c.GetDashboardsContext(ctx, page, maxPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		dashboards = append(dashboards, dashboardList.Results...)
+		if len(dashboardList.Results) == 0 || len(dashboards) >= dashboardList.Count {
+			return dashboards, nil
+		}
+	}
+}
+
+// GetDashboard gets a specific Dashboard along with its widgets
+func (c *Client) GetDashboard(id DashboardID) (*Dashboard, error) {
+	return c.GetDashboardContext(context.Background(), id)
+}
+
+// GetDashboardContext is GetDashboard bound to the given context
+func (c *Client) GetDashboardContext(ctx context.Context, id DashboardID) (*Dashboard, error) {
+	path := "/api/dashboards/" + id.String()
+
+	query := url.Values{}
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := Dashboard{}
+
+	err = json.Unmarshal(body, &dashboard)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dashboard, nil
+}
+
+// QueryIDs returns the ids of the queries behind the widgets of the Dashboard
+func (d *Dashboard) QueryIDs() []QueryID {
+	ids := []QueryID{}
+	seen := map[QueryID]bool{}
+
+	for _, widget := range d.Widgets {
+		if widget.Visualization == nil || widget.Visualization.Query == nil {
+			continue
+		}
+
+		id := widget.Visualization.Query.ID
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
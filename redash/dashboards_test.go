@@ -0,0 +1,67 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const salesDashboard = `{
+	"id": 1,
+	"name": "Sales",
+	"slug": "sales",
+	"widgets": [
+		{"id": 1, "text": "## Weekly sales"},
+		{"id": 2, "visualization": {"id": 10, "type": "TABLE", "query": {"id": 5, "data_source_id": 1}}},
+		{"id": 3, "visualization": {"id": 11, "type": "CHART", "query": {"id": 5, "data_source_id": 1}}},
+		{"id": 4, "visualization": {"id": 12, "type": "CHART", "query": {"id": 6, "data_source_id": 2}}}
+	]
+}`
+
+func TestGetDashboard(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards/1",
+		httpmock.NewStringResponder(200, salesDashboard))
+
+	dashboard, err := c.GetDashboard(1)
+	assert.Nil(err)
+	assert.Equal("sales", dashboard.Slug)
+	assert.Len(dashboard.Widgets, 4)
+	assert.Nil(dashboard.Widgets[0].Visualization)
+	assert.Equal([]QueryID{5, 6}, dashboard.QueryIDs())
+}
+
+func TestGetAllDashboards(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 1, "page": 1, "page_size": 250, "results": [{"id": 1, "name": "Sales"}]}`))
+
+	dashboards, err := c.GetAllDashboards()
+	assert.Nil(err)
+	assert.Len(dashboards, 1)
+	assert.Equal("Sales", dashboards[0].Name)
+}
@@ -0,0 +1,253 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// DataSourceDependencies lists the queries run against a DataSource and the
+// dashboards showing any of them. Archived queries are listed apart, as
+// they no longer run but would still be orphaned by deleting the DataSource
+type DataSourceDependencies struct {
+	Queries         []Query
+	ArchivedQueries []Query
+	Dashboards      []Dashboard
+}
+
+// IsEmpty returns true if nothing depends on the DataSource, archived
+// queries included
+func (d *DataSourceDependencies) IsEmpty() bool {
+	return len(d.Queries) == 0 && len(d.ArchivedQueries) == 0 && len(d.Dashboards) == 0
+}
+
+// DataSourceInUseError is returned when deleting a DataSource which queries
+// or dashboards still depend on
+type DataSourceInUseError struct {
+	ID           DataSourceID
+	Dependencies *DataSourceDependencies
+}
+
+func (e *DataSourceInUseError) Error() string {
+	return fmt.Sprintf("Data source %d is used by %d queries, %d archived queries and %d dashboards",
+		e.ID, len(e.Dependencies.Queries), len(e.Dependencies.ArchivedQueries), len(e.Dependencies.Dashboards))
+}
+
+// DeleteDataSourceOptions struct. Force deletes the DataSource even though
+// queries depend on it, orphaning them. ReplacementID, when set, moves those
+// queries, archived ones included, to another DataSource before deleting
+type DeleteDataSourceOptions struct {
+	Force         bool
+	ReplacementID DataSourceID
+}
+
+// GetDataSourceDependencies lists the queries and dashboards which depend on
+// a DataSource. Archived dashboards are not included. Dashboards are read
+// a few at a time, within Config.RateLimit
+func (c *Client) GetDataSourceDependencies(id DataSourceID) (*DataSourceDependencies, error) {
+	return c.GetDataSourceDependenciesContext(context.Background(), id)
+}
+
+// GetDataSourceDependenciesContext is GetDataSourceDependencies bound to the given context
+func (c *Client) GetDataSourceDependenciesContext(ctx context.Context, id DataSourceID) (*DataSourceDependencies, error) {
+	dependencies := DataSourceDependencies{
+		Queries:         []Query{},
+		ArchivedQueries: []Query{},
+		Dashboards:      []Dashboard{},
+	}
+
+	queries, err := c.GetAllQueriesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, query := range queries {
+		if query.DataSourceID == id {
+			dependencies.Queries = append(dependencies.Queries, query)
+		}
+	}
+
+	archived, err := c.getAllArchivedQueries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, query := range archived {
+		if query.DataSourceID == id {
+			dependencies.ArchivedQueries = append(dependencies.ArchivedQueries, query)
+		}
+	}
+
+	if len(dependencies.Queries) == 0 {
+		return &dependencies, nil
+	}
+
+	summaries, err := c.GetAllDashboardsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboards, err := c.getDashboards(ctx, summaries)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dashboard := range dashboards {
+		for _, widget := range dashboard.Widgets {
+			if widget.Visualization != nil && widget.Visualization.Query != nil &&
+				widget.Visualization.Query.DataSourceID == id {
+				dependencies.Dashboards = append(dependencies.Dashboards, *dashboard)
+				break
+			}
+		}
+	}
+
+	return &dependencies, nil
+}
+
+// getAllArchivedQueries returns every archived query, reading all pages of
+// the archive as GetAllQueries reads the queries
+func (c *Client) getAllArchivedQueries(ctx context.Context) ([]Query, error) {
+	queries := []Query{}
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Add("page", strconv.Itoa(page))
+		query.Add("page_size", strconv.Itoa(maxPageSize))
+		response, err := c.getContext(ctx, "/api/queries/archive", query)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		queryList := QueryList{}
+		err = json.Unmarshal(body, &queryList)
+		if err != nil {
+			return nil, err
+		}
+
+		queries = append(queries, queryList.Results...)
+		if len(queryList.Results) == 0 || len(queries) >= queryList.Count {
+			return queries, nil
+		}
+	}
+}
+
+// getDashboards reads the full dashboards of the given summaries, with at
+// most defaultBatchConcurrency requests at once. The first error stops the
+// requests not sent yet
+func (c *Client) getDashboards(ctx context.Context, summaries []Dashboard) ([]*Dashboard, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	dashboards := make([]*Dashboard, len(summaries))
+	slots := make(chan struct{}, defaultBatchConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for i, summary := range summaries {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, id DashboardID) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			dashboard, err := c.GetDashboardContext(ctx, id)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			dashboards[i] = dashboard
+		}(i, summary.ID)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return dashboards, nil
+}
+
+// SafeDeleteDataSource deletes a DataSource only once nothing depends on it.
+// Unlike DeleteDataSource, it returns a *DataSourceInUseError when queries
+// still run against the DataSource, unless the options force the deletion
+// or name a replacement to move those queries to first
+func (c *Client) SafeDeleteDataSource(id DataSourceID, options *DeleteDataSourceOptions) error {
+	return c.SafeDeleteDataSourceContext(context.Background(), id, options)
+}
+
+// SafeDeleteDataSourceContext is SafeDeleteDataSource bound to the given context
+func (c *Client) SafeDeleteDataSourceContext(ctx context.Context, id DataSourceID, options *DeleteDataSourceOptions) error {
+	if options == nil {
+		options = &DeleteDataSourceOptions{}
+	}
+
+	if options.ReplacementID == id {
+		return fmt.Errorf("Data source %d cannot replace itself", id)
+	}
+
+	dependencies, err := c.GetDataSourceDependenciesContext(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if options.ReplacementID != 0 {
+		_, err := c.GetDataSourceContext(ctx, options.ReplacementID)
+		if err != nil {
+			return fmt.Errorf("Error loading replacement data source %d: %v", options.ReplacementID, err)
+		}
+
+		moved := append(append([]Query{}, dependencies.Queries...), dependencies.ArchivedQueries...)
+		for _, query := range moved {
+			_, err := c.UpdateQueryContext(ctx, query.ID, &QueryUpdatePayload{
+				DataSourceID: DataSourceIDPtr(options.ReplacementID),
+				Version:      query.Version,
+			})
+			if err != nil {
+				return fmt.Errorf("Error moving query %d to data source %d: %v", query.ID, options.ReplacementID, err)
+			}
+		}
+	} else if !dependencies.IsEmpty() && !options.Force {
+		return &DataSourceInUseError{ID: id, Dependencies: dependencies}
+	}
+
+	return c.DeleteDataSourceContext(ctx, id)
+}
@@ -0,0 +1,103 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// dataSourcePause is the pause state of a DataSource before maintenance
+type dataSourcePause struct {
+	ID          DataSourceID
+	Paused      PauseState
+	PauseReason string
+}
+
+// DataSourceMaintenance holds the pause state data sources had before
+// PauseDataSources paused them, so Restore can put it back
+type DataSourceMaintenance struct {
+	client   *Client
+	previous []dataSourcePause
+}
+
+// PauseDataSources pauses every given DataSource with the same reason,
+// recording their previous state. If any of them cannot be paused, the
+// ones already paused are restored before returning the error
+func (c *Client) PauseDataSources(ids []DataSourceID, reason string) (*DataSourceMaintenance, error) {
+	return c.PauseDataSourcesContext(context.Background(), ids, reason)
+}
+
+// PauseDataSourcesContext is PauseDataSources bound to the given context
+func (c *Client) PauseDataSourcesContext(ctx context.Context, ids []DataSourceID, reason string) (*DataSourceMaintenance, error) {
+	maintenance := &DataSourceMaintenance{client: c}
+
+	for _, id := range ids {
+		dataSource, err := c.GetDataSourceContext(ctx, id)
+		if err == nil {
+			_, err = c.PauseDataSourceContext(ctx, id, reason)
+		}
+		if err != nil {
+			// the context may be what failed, so it does not bound the restore
+			if restoreErr := maintenance.Restore(); restoreErr != nil {
+				return nil, fmt.Errorf("Error pausing data source %d: %v (%v)", id, err, restoreErr)
+			}
+			return nil, fmt.Errorf("Error pausing data source %d: %v", id, err)
+		}
+
+		maintenance.previous = append(maintenance.previous, dataSourcePause{
+			ID:          id,
+			Paused:      dataSource.Paused,
+			PauseReason: dataSource.PauseReason,
+		})
+	}
+
+	return maintenance, nil
+}
+
+// Restore puts every DataSource back in the state it had before being
+// paused: resumed if it was running, paused with its former reason if it
+// was already paused. It carries on past failures and reports all of them
+func (m *DataSourceMaintenance) Restore() error {
+	return m.RestoreContext(context.Background())
+}
+
+// RestoreContext is Restore bound to the given context
+func (m *DataSourceMaintenance) RestoreContext(ctx context.Context) error {
+	failed := []string{}
+
+	for i := len(m.previous) - 1; i >= 0; i-- {
+		previous := m.previous[i]
+
+		var err error
+		if previous.Paused != 0 {
+			_, err = m.client.PauseDataSourceContext(ctx, previous.ID, previous.PauseReason)
+		} else {
+			_, err = m.client.ResumeDataSourceContext(ctx, previous.ID)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%d (%v)", previous.ID, err))
+			continue
+		}
+
+		m.previous = append(m.previous[:i], m.previous[i+1:]...)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Error restoring data sources: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// DataSourceOptions is implemented by the typed options of a data source
+// type. Flags and numbers whose zero value is meaningful are pointers in
+// them, so false and 0 can be set
+type DataSourceOptions interface {
+	// DataSourceType returns the DataSource.Type the options belong to
+	DataSourceType() string
+}
+
+// ExtraOptions is embedded in typed options to keep the options Redash
+// sent which they do not declare, such as ssh_tunnel, so that setting typed
+// options read from a data source does not drop them
+type ExtraOptions struct {
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (e ExtraOptions) extraOptions() map[string]json.RawMessage {
+	return e.Extra
+}
+
+func (e *ExtraOptions) setExtraOptions(extra map[string]json.RawMessage) {
+	e.Extra = extra
+}
+
+// extraOptionsCarrier is implemented by typed options embedding ExtraOptions
+type extraOptionsCarrier interface {
+	extraOptions() map[string]json.RawMessage
+	setExtraOptions(extra map[string]json.RawMessage)
+}
+
+// RawDataSourceOptions holds the options of a type without typed options
+type RawDataSourceOptions map[string]interface{}
+
+// DataSourceType returns an empty string as raw options fit any type
+func (o RawDataSourceOptions) DataSourceType() string { return "" }
+
+// PostgreSQLOptions struct
+type PostgreSQLOptions struct {
+	Host            string `json:"host,omitempty"`
+	Port            int    `json:"port,omitempty"`
+	User            string `json:"user,omitempty"`
+	Password        string `json:"password,omitempty"`
+	DBName          string `json:"dbname,omitempty"`
+	SSLMode         string `json:"sslmode,omitempty"`
+	SSLRootCertFile string `json:"sslrootcertFile,omitempty"`
+	SSLCertFile     string `json:"sslcertFile,omitempty"`
+	SSLKeyFile      string `json:"sslkeyFile,omitempty"`
+
+	ExtraOptions
+}
+
+// DataSourceType returns "pg"
+func (o PostgreSQLOptions) DataSourceType() string { return "pg" }
+
+// RedshiftOptions struct
+type RedshiftOptions struct {
+	Host                string `json:"host,omitempty"`
+	Port                int    `json:"port,omitempty"`
+	User                string `json:"user,omitempty"`
+	Password            string `json:"password,omitempty"`
+	DBName              string `json:"dbname,omitempty"`
+	SSLMode             string `json:"sslmode,omitempty"`
+	AdhocQueryGroup     string `json:"adhoc_query_group,omitempty"`
+	ScheduledQueryGroup string `json:"scheduled_query_group,omitempty"`
+
+	ExtraOptions
+}
+
+// DataSourceType returns "redshift"
+func (o RedshiftOptions) DataSourceType() string { return "redshift" }
+
+// MySQLOptions struct
+type MySQLOptions struct {
+	Host      string `json:"host,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	User      string `json:"user,omitempty"`
+	Password  string `json:"passwd,omitempty"`
+	DB        string `json:"db,omitempty"`
+	UseSSL    *bool  `json:"use_ssl,omitempty"`
+	SSLCACert string `json:"ssl_cacert,omitempty"`
+	SSLCert   string `json:"ssl_cert,omitempty"`
+	SSLKey    string `json:"ssl_key,omitempty"`
+
+	ExtraOptions
+}
+
+// DataSourceType returns "mysql"
+func (o MySQLOptions) DataSourceType() string { return "mysql" }
+
+// BigQueryOptions struct
+type BigQueryOptions struct {
+	ProjectID                      string `json:"projectId,omitempty"`
+	JSONKeyFile                    string `json:"jsonKeyFile,omitempty"`
+	TotalMBytesProcessedLimit      *int   `json:"totalMBytesProcessedLimit,omitempty"`
+	UserDefinedFunctionResourceURI string `json:"userDefinedFunctionResourceUri,omitempty"`
+	UseStandardSQL                 *bool  `json:"useStandardSql,omitempty"`
+	Location                       string `json:"location,omitempty"`
+	LoadSchema                     *bool  `json:"loadSchema,omitempty"`
+	MaximumBillingTier             *int   `json:"maximumBillingTier,omitempty"`
+
+	ExtraOptions
+}
+
+// DataSourceType returns "bigquery"
+func (o BigQueryOptions) DataSourceType() string { return "bigquery" }
+
+// SnowflakeOptions struct
+type SnowflakeOptions struct {
+	Account   string `json:"account,omitempty"`
+	User      string `json:"user,omitempty"`
+	Password  string `json:"password,omitempty"`
+	Warehouse string `json:"warehouse,omitempty"`
+	Database  string `json:"database,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Host      string `json:"host,omitempty"`
+
+	ExtraOptions
+}
+
+// DataSourceType returns "snowflake"
+func (o SnowflakeOptions) DataSourceType() string { return "snowflake" }
+
+// AthenaOptions struct
+type AthenaOptions struct {
+	Region           string   `json:"region,omitempty"`
+	AWSAccessKey     string   `json:"aws_access_key,omitempty"`
+	AWSSecretKey     string   `json:"aws_secret_key,omitempty"`
+	S3StagingDir     string   `json:"s3_staging_dir,omitempty"`
+	Schema           string   `json:"schema,omitempty"`
+	Glue             *bool    `json:"glue,omitempty"`
+	WorkGroup        string   `json:"work_group,omitempty"`
+	CostPerTB        *float64 `json:"cost_per_tb,omitempty"`
+	EncryptionOption string   `json:"encryption_option,omitempty"`
+	KMSKey           string   `json:"kms_key,omitempty"`
+	IAMRole          string   `json:"iam_role,omitempty"`
+	ExternalID       string   `json:"external_id,omitempty"`
+
+	ExtraOptions
+}
+
+// DataSourceType returns "athena"
+func (o AthenaOptions) DataSourceType() string { return "athena" }
+
+// ClickHouseOptions struct
+type ClickHouseOptions struct {
+	URL      string `json:"url,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	DBName   string `json:"dbname,omitempty"`
+	Timeout  *int   `json:"timeout,omitempty"`
+	Verify   *bool  `json:"verify,omitempty"`
+
+	ExtraOptions
+}
+
+// DataSourceType returns "clickhouse"
+func (o ClickHouseOptions) DataSourceType() string { return "clickhouse" }
+
+// MSSQLOptions struct
+type MSSQLOptions struct {
+	Server     string `json:"server,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	User       string `json:"user,omitempty"`
+	Password   string `json:"password,omitempty"`
+	DB         string `json:"db,omitempty"`
+	TDSVersion string `json:"tds_version,omitempty"`
+	Charset    string `json:"charset,omitempty"`
+
+	ExtraOptions
+}
+
+// DataSourceType returns "mssql"
+func (o MSSQLOptions) DataSourceType() string { return "mssql" }
+
+// QueryResultsOptions struct, the Query Results type takes no options
+type QueryResultsOptions struct{}
+
+// DataSourceType returns "results"
+func (o QueryResultsOptions) DataSourceType() string { return "results" }
+
+var (
+	dataSourceOptionsMu       sync.RWMutex
+	dataSourceOptionsRegistry = map[string]func() DataSourceOptions{
+		"pg":         func() DataSourceOptions { return &PostgreSQLOptions{} },
+		"redshift":   func() DataSourceOptions { return &RedshiftOptions{} },
+		"mysql":      func() DataSourceOptions { return &MySQLOptions{} },
+		"bigquery":   func() DataSourceOptions { return &BigQueryOptions{} },
+		"snowflake":  func() DataSourceOptions { return &SnowflakeOptions{} },
+		"athena":     func() DataSourceOptions { return &AthenaOptions{} },
+		"clickhouse": func() DataSourceOptions { return &ClickHouseOptions{} },
+		"mssql":      func() DataSourceOptions { return &MSSQLOptions{} },
+		"results":    func() DataSourceOptions { return &QueryResultsOptions{} },
+	}
+)
+
+// RegisterDataSourceOptions registers typed options for a data source type,
+// replacing any options already registered for it. The factory must return
+// a pointer so options can be decoded into it
+func RegisterDataSourceOptions(typeName string, factory func() DataSourceOptions) {
+	dataSourceOptionsMu.Lock()
+	defer dataSourceOptionsMu.Unlock()
+
+	dataSourceOptionsRegistry[typeName] = factory
+}
+
+// NewDataSourceOptions returns empty typed options for a data source type,
+// or false if none are registered for it
+func NewDataSourceOptions(typeName string) (DataSourceOptions, bool) {
+	dataSourceOptionsMu.RLock()
+	factory, exists := dataSourceOptionsRegistry[typeName]
+	dataSourceOptionsMu.RUnlock()
+
+	if !exists {
+		return nil, false
+	}
+
+	return factory(), true
+}
+
+// TypedOptions decodes the options of the DataSource into the typed options
+// registered for its type, or returns RawDataSourceOptions for unknown types
+func (ds *DataSource) TypedOptions() (DataSourceOptions, error) {
+	options, exists := NewDataSourceOptions(ds.Type)
+	if !exists {
+		return RawDataSourceOptions(ds.Options), nil
+	}
+
+	payload, err := json.Marshal(ds.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := unmarshalWithExtra(payload, options)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid options for type %s: %v", ds.Type, err)
+	}
+
+	if carrier, ok := options.(extraOptionsCarrier); ok {
+		carrier.setExtraOptions(extra)
+	}
+
+	return options, nil
+}
+
+// SetOptions replaces the options of the DataSource with the given typed
+// options, setting its type when empty
+func (ds *DataSource) SetOptions(options DataSourceOptions) error {
+	optionsType := options.DataSourceType()
+	if optionsType != "" {
+		if ds.Type == "" {
+			ds.Type = optionsType
+		} else if ds.Type != optionsType {
+			return fmt.Errorf("Options for type %s set on data source of type %s", optionsType, ds.Type)
+		}
+	}
+
+	if raw, ok := options.(RawDataSourceOptions); ok {
+		ds.Options = map[string]interface{}(raw)
+		return nil
+	}
+
+	var extra map[string]json.RawMessage
+	if carrier, ok := options.(interface {
+		extraOptions() map[string]json.RawMessage
+	}); ok {
+		extra = carrier.extraOptions()
+	}
+
+	payload, err := marshalWithExtra(options, extra)
+	if err != nil {
+		return err
+	}
+
+	optionsMap := map[string]interface{}{}
+	err = json.Unmarshal(payload, &optionsMap)
+	if err != nil {
+		return err
+	}
+
+	ds.Options = optionsMap
+	return nil
+}
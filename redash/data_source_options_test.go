@@ -0,0 +1,143 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataSourceSetOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	dataSource := DataSource{Name: "Warehouse"}
+	err := dataSource.SetOptions(RedshiftOptions{
+		Host:     "localhost",
+		Port:     5439,
+		User:     "user_name",
+		Password: "S3cuR3PaSsW0rD",
+		DBName:   "my_database",
+	})
+	assert.Nil(err)
+
+	assert.Equal("redshift", dataSource.Type)
+	assert.Equal("my_database", dataSource.Options["dbname"])
+	assert.Equal(float64(5439), dataSource.Options["port"])
+	assert.NotContains(dataSource.Options, "sslmode")
+
+	err = dataSource.SetOptions(&MySQLOptions{Host: "localhost"})
+	assert.NotNil(err)
+}
+
+func TestDataSourceTypedOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	dataSource := DataSource{
+		Type: "mysql",
+		Options: map[string]interface{}{
+			"host":    "localhost",
+			"port":    float64(3306),
+			"passwd":  "S3cuR3PaSsW0rD",
+			"db":      "my_database",
+			"use_ssl": true,
+		},
+	}
+
+	options, err := dataSource.TypedOptions()
+	assert.Nil(err)
+
+	mysql, ok := options.(*MySQLOptions)
+	assert.True(ok)
+	assert.Equal(3306, mysql.Port)
+	assert.Equal("S3cuR3PaSsW0rD", mysql.Password)
+	assert.Equal(Bool(true), mysql.UseSSL)
+
+	dataSource.Options["port"] = "not a number"
+	_, err = dataSource.TypedOptions()
+	assert.NotNil(err)
+}
+
+func TestDataSourceOptionsRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	dataSource := DataSource{
+		Type: "clickhouse",
+		Options: map[string]interface{}{
+			"url":        "https://clickhouse.acme.com",
+			"verify":     true,
+			"timeout":    float64(30),
+			"ssh_tunnel": map[string]interface{}{"ssh_host": "bastion.acme.com", "ssh_port": float64(22)},
+		},
+	}
+
+	options, err := dataSource.TypedOptions()
+	assert.Nil(err)
+	clickhouse := options.(*ClickHouseOptions)
+	assert.Equal(`{"ssh_host":"bastion.acme.com","ssh_port":22}`, string(clickhouse.Extra["ssh_tunnel"]))
+
+	clickhouse.Verify = Bool(false)
+	clickhouse.Timeout = Int(0)
+	assert.Nil(dataSource.SetOptions(clickhouse))
+	assert.Equal(map[string]interface{}{
+		"url":        "https://clickhouse.acme.com",
+		"verify":     false,
+		"timeout":    float64(0),
+		"ssh_tunnel": map[string]interface{}{"ssh_host": "bastion.acme.com", "ssh_port": float64(22)},
+	}, dataSource.Options)
+
+	// declared options win over extra ones of the same name
+	clickhouse.Extra["url"] = []byte(`"https://other.acme.com"`)
+	assert.Nil(dataSource.SetOptions(*clickhouse))
+	assert.Equal("https://clickhouse.acme.com", dataSource.Options["url"])
+}
+
+func TestDataSourceTypedOptionsUnknownType(t *testing.T) {
+	assert := assert.New(t)
+
+	dataSource := DataSource{
+		Type:    "unknown",
+		Options: map[string]interface{}{"anything": "goes"},
+	}
+
+	options, err := dataSource.TypedOptions()
+	assert.Nil(err)
+	assert.Equal(RawDataSourceOptions{"anything": "goes"}, options)
+
+	err = dataSource.SetOptions(RawDataSourceOptions{"other": "value"})
+	assert.Nil(err)
+	assert.Equal("unknown", dataSource.Type)
+	assert.Equal("value", dataSource.Options["other"])
+}
+
+type customOptions struct {
+	Endpoint string `json:"endpoint"`
+}
+
+func (o customOptions) DataSourceType() string { return "custom" }
+
+func TestRegisterDataSourceOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	RegisterDataSourceOptions("custom", func() DataSourceOptions { return &customOptions{} })
+
+	dataSource := DataSource{
+		Type:    "custom",
+		Options: map[string]interface{}{"endpoint": "https://com.acme/"},
+	}
+
+	options, err := dataSource.TypedOptions()
+	assert.Nil(err)
+	assert.Equal(&customOptions{Endpoint: "https://com.acme/"}, options)
+}
@@ -0,0 +1,247 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// DataSourceTypeChangeKind describes how a type or property differs between
+// two snapshots
+type DataSourceTypeChangeKind string
+
+// Kinds of DataSourceTypeChange
+const (
+	TypeAdded           DataSourceTypeChangeKind = "type_added"
+	TypeRemoved         DataSourceTypeChangeKind = "type_removed"
+	PropertyAdded       DataSourceTypeChangeKind = "property_added"
+	PropertyRemoved     DataSourceTypeChangeKind = "property_removed"
+	PropertyTypeChanged DataSourceTypeChangeKind = "property_type_changed"
+	DefaultChanged      DataSourceTypeChangeKind = "default_changed"
+	RequiredAdded       DataSourceTypeChangeKind = "required_added"
+	RequiredRemoved     DataSourceTypeChangeKind = "required_removed"
+	SecretAdded         DataSourceTypeChangeKind = "secret_added"
+	SecretRemoved       DataSourceTypeChangeKind = "secret_removed"
+)
+
+// DataSourceTypeChange is a single difference between two snapshots of data
+// source types. Property is empty for changes to the type itself
+type DataSourceTypeChange struct {
+	Type     string                   `json:"type"`
+	Property string                   `json:"property,omitempty"`
+	Kind     DataSourceTypeChangeKind `json:"kind"`
+	Old      interface{}              `json:"old,omitempty"`
+	New      interface{}              `json:"new,omitempty"`
+}
+
+// String returns a one line description of the change
+func (c DataSourceTypeChange) String() string {
+	switch c.Kind {
+	case TypeAdded, TypeRemoved:
+		return fmt.Sprintf("%s: %s", c.Type, c.Kind)
+	case PropertyTypeChanged, DefaultChanged:
+		return fmt.Sprintf("%s.%s: %s (%v -> %v)", c.Type, c.Property, c.Kind, c.Old, c.New)
+	default:
+		return fmt.Sprintf("%s.%s: %s", c.Type, c.Property, c.Kind)
+	}
+}
+
+// WriteDataSourceTypes writes a JSON snapshot of data source types, in the
+// format returned by GetDataSourceTypes
+func WriteDataSourceTypes(w io.Writer, dataSourceTypes []DataSourceType) error {
+	payload, err := json.MarshalIndent(dataSourceTypes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(payload, '\n'))
+	return err
+}
+
+// ReadDataSourceTypes reads a JSON snapshot of data source types
+func ReadDataSourceTypes(r io.Reader) ([]DataSourceType, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dataSourceTypes := []DataSourceType{}
+	err = json.Unmarshal(body, &dataSourceTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return dataSourceTypes, nil
+}
+
+// SaveDataSourceTypesFile fetches all available types and snapshots them to
+// the named file
+func (c *Client) SaveDataSourceTypesFile(name string) error {
+	return c.SaveDataSourceTypesFileContext(context.Background(), name)
+}
+
+// SaveDataSourceTypesFileContext is SaveDataSourceTypesFile bound to the given context
+func (c *Client) SaveDataSourceTypesFileContext(ctx context.Context, name string) error {
+	dataSourceTypes, err := c.GetDataSourceTypesContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = WriteDataSourceTypes(file, dataSourceTypes)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// LoadDataSourceTypesFile reads a snapshot written by SaveDataSourceTypesFile.
+// Setting the result as Config.DataSourceTypes lets the client validate data
+// sources without contacting Redash
+func LoadDataSourceTypesFile(name string) ([]DataSourceType, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadDataSourceTypes(file)
+}
+
+// DiffDataSourceTypes reports the schema changes between two snapshots of
+// data source types, ordered by type and property
+func DiffDataSourceTypes(oldTypes, newTypes []DataSourceType) []DataSourceTypeChange {
+	changes := []DataSourceTypeChange{}
+
+	oldByType := map[string]DataSourceType{}
+	for _, dst := range oldTypes {
+		oldByType[dst.Type] = dst
+	}
+	newByType := map[string]DataSourceType{}
+	for _, dst := range newTypes {
+		newByType[dst.Type] = dst
+	}
+
+	for typeName := range oldByType {
+		if _, exists := newByType[typeName]; !exists {
+			changes = append(changes, DataSourceTypeChange{Type: typeName, Kind: TypeRemoved})
+		}
+	}
+
+	for typeName, newType := range newByType {
+		oldType, exists := oldByType[typeName]
+		if !exists {
+			changes = append(changes, DataSourceTypeChange{Type: typeName, Kind: TypeAdded})
+			continue
+		}
+
+		changes = append(changes, diffDataSourceType(oldType, newType)...)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Type != changes[j].Type {
+			return changes[i].Type < changes[j].Type
+		}
+		if changes[i].Property != changes[j].Property {
+			return changes[i].Property < changes[j].Property
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+
+	return changes
+}
+
+func diffDataSourceType(oldType, newType DataSourceType) []DataSourceTypeChange {
+	changes := []DataSourceTypeChange{}
+	oldSchema := oldType.ConfigurationSchema
+	newSchema := newType.ConfigurationSchema
+
+	for propName := range oldSchema.Properties {
+		if _, exists := newSchema.Properties[propName]; !exists {
+			changes = append(changes, DataSourceTypeChange{Type: newType.Type, Property: propName, Kind: PropertyRemoved})
+		}
+	}
+
+	for propName, newProp := range newSchema.Properties {
+		oldProp, exists := oldSchema.Properties[propName]
+		if !exists {
+			changes = append(changes, DataSourceTypeChange{Type: newType.Type, Property: propName, Kind: PropertyAdded})
+			continue
+		}
+
+		if oldProp.Type != newProp.Type {
+			changes = append(changes, DataSourceTypeChange{
+				Type:     newType.Type,
+				Property: propName,
+				Kind:     PropertyTypeChanged,
+				Old:      oldProp.Type,
+				New:      newProp.Type,
+			})
+		}
+
+		if !reflect.DeepEqual(oldProp.Default, newProp.Default) {
+			changes = append(changes, DataSourceTypeChange{
+				Type:     newType.Type,
+				Property: propName,
+				Kind:     DefaultChanged,
+				Old:      oldProp.Default,
+				New:      newProp.Default,
+			})
+		}
+	}
+
+	changes = append(changes, diffStringSets(newType.Type, oldSchema.Required, newSchema.Required, RequiredAdded, RequiredRemoved)...)
+	changes = append(changes, diffStringSets(newType.Type, oldSchema.Secret, newSchema.Secret, SecretAdded, SecretRemoved)...)
+
+	return changes
+}
+
+func diffStringSets(typeName string, oldSet, newSet []string, added, removed DataSourceTypeChangeKind) []DataSourceTypeChange {
+	changes := []DataSourceTypeChange{}
+
+	oldMembers := map[string]bool{}
+	for _, member := range oldSet {
+		oldMembers[member] = true
+	}
+	newMembers := map[string]bool{}
+	for _, member := range newSet {
+		newMembers[member] = true
+	}
+
+	for member := range oldMembers {
+		if !newMembers[member] {
+			changes = append(changes, DataSourceTypeChange{Type: typeName, Property: member, Kind: removed})
+		}
+	}
+	for member := range newMembers {
+		if !oldMembers[member] {
+			changes = append(changes, DataSourceTypeChange{Type: typeName, Property: member, Kind: added})
+		}
+	}
+
+	return changes
+}
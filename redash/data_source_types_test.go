@@ -0,0 +1,141 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataSourceTypesSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/types",
+		httpmock.NewStringResponder(200, redshiftDataSourceTypes))
+
+	name := filepath.Join(t.TempDir(), "types.json")
+	err := c.SaveDataSourceTypesFile(name)
+	assert.Nil(err)
+
+	dataSourceTypes, err := LoadDataSourceTypesFile(name)
+	assert.Nil(err)
+	assert.Len(dataSourceTypes, 1)
+	assert.Equal("redshift", dataSourceTypes[0].Type)
+	assert.Equal("Database Name", dataSourceTypes[0].ConfigurationSchema.Properties["dbname"].Title)
+
+	var buf bytes.Buffer
+	err = WriteDataSourceTypes(&buf, dataSourceTypes)
+	assert.Nil(err)
+	assert.Contains(buf.String(), `"title": "Database Name"`)
+}
+
+func TestOfflineDataSourceTypes(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	dataSourceTypes, err := ReadDataSourceTypes(strings.NewReader(redshiftDataSourceTypes))
+	assert.Nil(err)
+
+	c, _ := NewClient(&Config{
+		RedashURI:       "https://com.acme/",
+		APIKey:          "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		StrictMode:      true,
+		DataSourceTypes: dataSourceTypes,
+	})
+
+	_, err = c.SanitizeDataSourceOptions(&DataSource{
+		Type:    "redshift",
+		Options: map[string]interface{}{"host": "localhost"},
+	})
+	assert.NotNil(err)
+	assert.Equal(0, httpmock.GetTotalCallCount())
+}
+
+func TestValidateDataSourceOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	dataSourceTypes, _ := ReadDataSourceTypes(strings.NewReader(redshiftDataSourceTypes))
+
+	dataSource := DataSource{
+		Type: "redshift",
+		Options: map[string]interface{}{
+			"host":     "localhost",
+			"port":     5439,
+			"user":     "user_name",
+			"password": "S3cuR3PaSsW0rD",
+			"dbname":   "my_database",
+			"unknown":  "value",
+		},
+	}
+
+	_, err := ValidateDataSourceOptions(&dataSource, dataSourceTypes, true)
+	assert.NotNil(err)
+
+	sanitized, err := ValidateDataSourceOptions(&dataSource, dataSourceTypes, false)
+	assert.Nil(err)
+	assert.NotContains(sanitized.Options, "unknown")
+
+	_, err = ValidateDataSourceOptions(&DataSource{Type: "unknown"}, dataSourceTypes, false)
+	assert.NotNil(err)
+}
+
+func TestDiffDataSourceTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	oldTypes, _ := ReadDataSourceTypes(strings.NewReader(redshiftDataSourceTypes))
+	newTypes, _ := ReadDataSourceTypes(strings.NewReader(`[{
+		"type": "redshift",
+		"name": "Redshift",
+		"configuration_schema": {
+			"secret": ["password", "iam_role"],
+			"required": ["dbname", "user", "host", "port"],
+			"properties": {
+				"host": {"type": "string"},
+				"port": {"type": "number", "default": 5439},
+				"user": {"type": "string"},
+				"password": {"type": "string"},
+				"iam_role": {"type": "string"}
+			}
+		}
+	}, {
+		"type": "pg",
+		"name": "PostgreSQL"
+	}]`))
+
+	assert.Empty(DiffDataSourceTypes(oldTypes, oldTypes))
+
+	changes := DiffDataSourceTypes(oldTypes, newTypes)
+	assert.Equal([]DataSourceTypeChange{
+		{Type: "pg", Kind: TypeAdded},
+		{Type: "redshift", Property: "dbname", Kind: PropertyRemoved},
+		{Type: "redshift", Property: "iam_role", Kind: PropertyAdded},
+		{Type: "redshift", Property: "iam_role", Kind: SecretAdded},
+		{Type: "redshift", Property: "password", Kind: RequiredRemoved},
+		{Type: "redshift", Property: "port", Kind: DefaultChanged, New: float64(5439)},
+	}, changes)
+	assert.Equal("redshift.port: default_changed (<nil> -> 5439)", changes[5].String())
+
+	changes = DiffDataSourceTypes(newTypes, oldTypes)
+	assert.Contains(changes, DataSourceTypeChange{Type: "pg", Kind: TypeRemoved})
+}
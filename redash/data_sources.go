@@ -14,27 +14,106 @@
 package redash
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
-	"strconv"
-
-	log "github.com/sirupsen/logrus"
 )
 
+// DataSourceSecretMask is the placeholder Redash returns in place of the
+// value of any option listed in ConfigurationSchema.Secret
+const DataSourceSecretMask = "--------"
+
 // DataSource struct
 type DataSource struct {
-	ID                 int                    `json:"id,omitempty"`
+	ID                 DataSourceID           `json:"id,omitempty"`
 	Name               string                 `json:"name,omitempty"`
 	ScheduledQueueName string                 `json:"scheduled_queue_name,omitempty"`
 	QueueName          string                 `json:"queue_name,omitempty"`
 	Options            map[string]interface{} `json:"options,omitempty"`
-	Paused             int                    `json:"paused,omitempty"`
+	Paused             PauseState             `json:"paused,omitempty"`
 	PauseReason        string                 `json:"pause_reason,omitempty"`
 	Type               string                 `json:"type,omitempty"`
 	Syntax             string                 `json:"syntax,omitempty"`
-	Groups             map[int]bool           `json:"groups,omitempty"`
+	Groups             map[GroupID]bool       `json:"groups,omitempty"`
+
+	// Extra holds the fields Redash sent which DataSource does not declare
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the fields Redash sends which DataSource does not declare
+// in Extra
+func (ds *DataSource) UnmarshalJSON(data []byte) error {
+	type dataSource DataSource
+	extra, err := unmarshalWithExtra(data, (*dataSource)(ds))
+	if err != nil {
+		return err
+	}
+
+	ds.Extra = extra
+	return nil
+}
+
+// MarshalJSON sends the fields kept in Extra back along with the declared
+// ones. The pause state and reason are left out, as they are only changed
+// through PauseDataSource and ResumeDataSource
+func (ds DataSource) MarshalJSON() ([]byte, error) {
+	type dataSource DataSource
+	ds.Paused, ds.PauseReason = 0, ""
+	return marshalWithExtra(dataSource(ds), ds.Extra)
+}
+
+func (ds DataSource) extraFields() map[string]json.RawMessage {
+	return ds.Extra
+}
+
+// PauseState reports whether a DataSource is paused, non-zero meaning it is.
+// Redash encodes it either as a boolean or as a number, depending on its
+// version; it is only decoded, as pausing goes through PauseDataSource and
+// DataSource leaves it out of write payloads
+type PauseState int
+
+// UnmarshalJSON accepts booleans, numbers and null
+func (p *PauseState) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		*p = 0
+		if v {
+			*p = 1
+		}
+	case float64:
+		*p = PauseState(v)
+	case nil:
+		*p = 0
+	default:
+		return fmt.Errorf("Invalid pause state: %s", data)
+	}
+
+	return nil
+}
+
+// DataSourcePatchPayload struct for partially mutating data sources. Only
+// the fields set are changed, a pointer to an empty string clearing the
+// field; an option set to nil is removed
+type DataSourcePatchPayload struct {
+	Name               *string
+	ScheduledQueueName *string
+	QueueName          *string
+	Type               *string
+	Syntax             *string
+	Options            map[string]interface{}
+}
+
+// DataSourcePausePayload struct
+type DataSourcePausePayload struct {
+	Reason string `json:"reason,omitempty"`
 }
 
 // DataSourceType struct
@@ -52,16 +131,94 @@ type DataSourceType struct {
 
 // DataSourceTypePropertyField struct
 type DataSourceTypePropertyField struct {
-	Type    string
-	Title   string
-	Default interface{}
+	Type    string      `json:"type,omitempty"`
+	Title   string      `json:"title,omitempty"`
+	Default interface{} `json:"default,omitempty"`
+}
+
+// DataSourceTestResult struct
+type DataSourceTestResult struct {
+	Ok      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// DataSourceSchemaTable struct
+type DataSourceSchemaTable struct {
+	Name    string                   `json:"name"`
+	Columns []DataSourceSchemaColumn `json:"columns,omitempty"`
+}
+
+// DataSourceSchemaColumn struct. Type is empty on Redash versions which only
+// report column names
+type DataSourceSchemaColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// UnmarshalJSON accepts columns given either as a name or as an object
+func (col *DataSourceSchemaColumn) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*col = DataSourceSchemaColumn{Name: name}
+		return nil
+	}
+
+	type column DataSourceSchemaColumn
+	return json.Unmarshal(data, (*column)(col))
+}
+
+// dataSourceSchemaResponse covers both the synchronous schema response and
+// the job based one of newer Redash versions
+type dataSourceSchemaResponse struct {
+	Schema []DataSourceSchemaTable `json:"schema"`
+	Job    *Job                    `json:"job"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// IsSecret returns true if the named option is listed as a secret in the
+// type's configuration schema
+func (dst *DataSourceType) IsSecret(option string) bool {
+	for _, secret := range dst.ConfigurationSchema.Secret {
+		if secret == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StripMaskedSecrets returns a copy of the data source without the secret
+// options of the given type which still hold DataSourceSecretMask, so the
+// masked value is never sent back to Redash as if it were the real one. The
+// given data source is left unchanged
+func StripMaskedSecrets(dataSource *DataSource, dataSourceType *DataSourceType) *DataSource {
+	stripped := *dataSource
+	if dataSource.Options != nil {
+		stripped.Options = make(map[string]interface{}, len(dataSource.Options))
+		for propName, propVal := range dataSource.Options {
+			if dataSourceType.IsSecret(propName) && propVal == DataSourceSecretMask {
+				continue
+			}
+			stripped.Options[propName] = propVal
+		}
+	}
+
+	return &stripped
 }
 
 //GetDataSources gets an array of all DataSources available
 func (c *Client) GetDataSources() (*[]DataSource, error) {
+	return c.GetDataSourcesContext(context.Background())
+}
+
+// GetDataSourcesContext is GetDataSources bound to the given context
+func (c *Client) GetDataSourcesContext(ctx context.Context) (*[]DataSource, error) {
 	path := "/api/data_sources"
 	query := url.Values{}
-	response, err := c.get(path, query)
+	response, err := c.getContext(ctx, path, query)
 
 	if err != nil {
 		return nil, err
@@ -70,7 +227,7 @@ func (c *Client) GetDataSources() (*[]DataSource, error) {
 	body, _ := ioutil.ReadAll(response.Body)
 
 	dataSources := []DataSource{}
-	err = json.Unmarshal(body, &dataSources)
+	err = c.unmarshal(body, &dataSources)
 	if err != nil {
 		return nil, err
 	}
@@ -79,10 +236,15 @@ func (c *Client) GetDataSources() (*[]DataSource, error) {
 }
 
 //GetDataSource gets a specific DataSource
-func (c *Client) GetDataSource(id int) (*DataSource, error) {
-	path := "/api/data_sources/" + strconv.Itoa(id)
+func (c *Client) GetDataSource(id DataSourceID) (*DataSource, error) {
+	return c.GetDataSourceContext(context.Background(), id)
+}
+
+// GetDataSourceContext is GetDataSource bound to the given context
+func (c *Client) GetDataSourceContext(ctx context.Context, id DataSourceID) (*DataSource, error) {
+	path := "/api/data_sources/" + id.String()
 	query := url.Values{}
-	response, err := c.get(path, query)
+	response, err := c.getContext(ctx, path, query)
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +257,7 @@ func (c *Client) GetDataSource(id int) (*DataSource, error) {
 
 	dataSource := DataSource{}
 
-	err = json.Unmarshal(body, &dataSource)
+	err = c.unmarshal(body, &dataSource)
 	if err != nil {
 		return nil, err
 	}
@@ -103,11 +265,111 @@ func (c *Client) GetDataSource(id int) (*DataSource, error) {
 	return &dataSource, nil
 }
 
-//GetDataSourceTypes gets all available types with configuration details
+// GetDataSourceByName gets the DataSource with the given name. The error
+// wraps ErrNotFound or ErrAmbiguous when no data source or several data
+// sources match
+func (c *Client) GetDataSourceByName(name string) (*DataSource, error) {
+	return c.GetDataSourceByNameContext(context.Background(), name)
+}
+
+// GetDataSourceByNameContext is GetDataSourceByName bound to the given context
+func (c *Client) GetDataSourceByNameContext(ctx context.Context, name string) (*DataSource, error) {
+	dataSources, err := c.GetDataSourcesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []DataSource{}
+	for _, dataSource := range *dataSources {
+		if dataSource.Name == name {
+			matches = append(matches, dataSource)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("No data source named %q: %w", name, ErrNotFound)
+	case 1:
+		return c.GetDataSourceContext(ctx, matches[0].ID)
+	default:
+		return nil, fmt.Errorf("%d data sources named %q: %w", len(matches), name, ErrAmbiguous)
+	}
+}
+
+// EnsureDataSource makes sure a DataSource named as the given one exists
+// and matches it: it is created when missing, and patched when its type or
+// any of the given options differ. Secret options are only compared when
+// Redash does not mask them, so a masked secret never counts as drift
+func (c *Client) EnsureDataSource(dataSource *DataSource) (*DataSource, error) {
+	return c.EnsureDataSourceContext(context.Background(), dataSource)
+}
+
+// EnsureDataSourceContext is EnsureDataSource bound to the given context
+func (c *Client) EnsureDataSourceContext(ctx context.Context, dataSource *DataSource) (*DataSource, error) {
+	current, err := c.GetDataSourceByNameContext(ctx, dataSource.Name)
+	if errors.Is(err, ErrNotFound) {
+		return c.CreateDataSourceContext(ctx, dataSource)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !dataSourceDrifted(current, dataSource) {
+		return current, nil
+	}
+
+	patch := DataSourcePatchPayload{Options: dataSource.Options}
+	if dataSource.Type != "" {
+		patch.Type = String(dataSource.Type)
+	}
+
+	return c.PatchDataSourceContext(ctx, current.ID, &patch)
+}
+
+// dataSourceDrifted returns true if the current DataSource differs from the
+// desired one in its type or any of the desired options
+func dataSourceDrifted(current *DataSource, desired *DataSource) bool {
+	if desired.Type != "" && desired.Type != current.Type {
+		return true
+	}
+
+	for propName, desiredVal := range desired.Options {
+		currentVal, exists := current.Options[propName]
+		if !exists {
+			return true
+		}
+		if currentVal == DataSourceSecretMask {
+			continue
+		}
+
+		currentJSON, err := json.Marshal(currentVal)
+		if err != nil {
+			return true
+		}
+		desiredJSON, err := json.Marshal(desiredVal)
+		if err != nil || string(currentJSON) != string(desiredJSON) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//GetDataSourceTypes gets all available types with configuration details,
+// or the ones set in Config.DataSourceTypes without contacting Redash
 func (c *Client) GetDataSourceTypes() ([]DataSourceType, error) {
+	return c.GetDataSourceTypesContext(context.Background())
+}
+
+// GetDataSourceTypesContext is GetDataSourceTypes bound to the given context
+func (c *Client) GetDataSourceTypesContext(ctx context.Context) ([]DataSourceType, error) {
+	if c.Config.DataSourceTypes != nil {
+		return c.Config.DataSourceTypes, nil
+	}
+
 	path := "/api/data_sources/types"
 	query := url.Values{}
-	response, err := c.get(path, query)
+	response, err := c.getContext(ctx, path, query)
 
 	if err != nil {
 		return nil, err
@@ -121,19 +383,73 @@ func (c *Client) GetDataSourceTypes() ([]DataSourceType, error) {
 		return nil, err
 	}
 
+	c.RegisterDataSourceSecrets(dataSourceTypes)
+
 	return dataSourceTypes, nil
 }
 
+// GetDataSourceType gets the configuration details of a single type
+func (c *Client) GetDataSourceType(typeName string) (*DataSourceType, error) {
+	return c.GetDataSourceTypeContext(context.Background(), typeName)
+}
+
+// GetDataSourceTypeContext is GetDataSourceType bound to the given context
+func (c *Client) GetDataSourceTypeContext(ctx context.Context, typeName string) (*DataSourceType, error) {
+	dataSourceTypes, err := c.GetDataSourceTypesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dst := range dataSourceTypes {
+		if dst.Type == typeName {
+			return &dst, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown data source type: %s", typeName)
+}
+
 // SanitizeDataSourceOptions checks the validity of the options field in a
 // DataSource.Option against Redash's API and cleans up when possible
 func (c *Client) SanitizeDataSourceOptions(dataSource *DataSource) (*DataSource, error) {
-	whitelistedProps := map[string]bool{
-		"ssh_tunnel": true,
-	}
+	return c.SanitizeDataSourceOptionsContext(context.Background(), dataSource)
+}
+
+// SanitizeDataSourceOptionsContext is SanitizeDataSourceOptions bound to the given context
+func (c *Client) SanitizeDataSourceOptionsContext(ctx context.Context, dataSource *DataSource) (*DataSource, error) {
+	return c.sanitizeDataSourceOptions(ctx, dataSource, false)
+}
 
-	dataSourceTypes, err := c.GetDataSourceTypes()
+// sanitizeDataSourceOptions implements SanitizeDataSourceOptions. When
+// preserveSecrets is set, required secret options may be missing as they
+// have been left out to keep their current value
+func (c *Client) sanitizeDataSourceOptions(ctx context.Context, dataSource *DataSource, preserveSecrets bool) (*DataSource, error) {
+	dataSourceTypes, err := c.GetDataSourceTypesContext(ctx)
 	if err != nil {
-		fmt.Println(err)
+		c.logger().Warn("Could not load data source types", Field{"error", err.Error()})
+	}
+
+	return validateDataSourceOptions(dataSource, dataSourceTypes, c.IsStrict(), preserveSecrets, c.logger())
+}
+
+// ValidateDataSourceOptions checks the validity of the options field in a
+// DataSource.Option against the given types and cleans up when possible.
+// It behaves as SanitizeDataSourceOptions without contacting Redash, so
+// snapshots loaded with LoadDataSourceTypesFile can be used offline. Unlike
+// SanitizeDataSourceOptions, types missing from dataSourceTypes are an error
+func ValidateDataSourceOptions(dataSource *DataSource, dataSourceTypes []DataSourceType, strict bool) (*DataSource, error) {
+	for _, dst := range dataSourceTypes {
+		if dst.Type == dataSource.Type {
+			return validateDataSourceOptions(dataSource, dataSourceTypes, strict, false, NopLogger{})
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown data source type: %s", dataSource.Type)
+}
+
+func validateDataSourceOptions(dataSource *DataSource, dataSourceTypes []DataSourceType, strict bool, preserveSecrets bool, logger Logger) (*DataSource, error) {
+	whitelistedProps := map[string]bool{
+		"ssh_tunnel": true,
 	}
 
 	for _, dst := range dataSourceTypes {
@@ -142,7 +458,7 @@ func (c *Client) SanitizeDataSourceOptions(dataSource *DataSource) (*DataSource,
 			for _, required := range dst.ConfigurationSchema.Required {
 				// does dataSource.Options have everything in configuration_schema.required[] ?
 				_, exists := dataSource.Options[required]
-				if !exists {
+				if !exists && !(preserveSecrets && dst.IsSecret(required)) {
 					return nil, fmt.Errorf("Required field missing: " + required)
 				}
 			}
@@ -152,23 +468,23 @@ func (c *Client) SanitizeDataSourceOptions(dataSource *DataSource) (*DataSource,
 				_, exists := dst.ConfigurationSchema.Properties[propName]
 
 				if whitelistedProps[propName] {
-					log.Warn(fmt.Sprintf("[WARN] Whitelisted field (%s)", propName))
+					logger.Warn("Whitelisted field", Field{"field", propName})
 					continue
 				}
 
 				if !exists {
-					if c.IsStrict() {
+					if strict {
 						return nil, fmt.Errorf("Invalid field (%s) for type: %s", propName, dataSource.Type)
 					}
 
-					log.Warn(fmt.Sprintf("[WARN] Ignoring invalid field (%s) for type: %s", propName, dataSource.Type))
+					logger.Warn("Ignoring invalid field", Field{"field", propName}, Field{"type", dataSource.Type})
 					delete((*dataSource).Options, propName)
 					continue
 				}
 
 				// is the input value a valid data type?
 				switch propVal.(type) {
-				case int:
+				case int, float64:
 					if dst.ConfigurationSchema.Properties[propName].Type != "number" {
 						return nil, fmt.Errorf("Invalid value type for %s", propName)
 					}
@@ -192,9 +508,14 @@ func (c *Client) SanitizeDataSourceOptions(dataSource *DataSource) (*DataSource,
 
 //CreateDataSource creates a new DataSource
 func (c *Client) CreateDataSource(dataSourcePayload *DataSource) (*DataSource, error) {
+	return c.CreateDataSourceContext(context.Background(), dataSourcePayload)
+}
+
+// CreateDataSourceContext is CreateDataSource bound to the given context
+func (c *Client) CreateDataSourceContext(ctx context.Context, dataSourcePayload *DataSource) (*DataSource, error) {
 	path := "/api/data_sources"
 
-	dataSourcePayload, err := c.SanitizeDataSourceOptions(dataSourcePayload)
+	dataSourcePayload, err := c.SanitizeDataSourceOptionsContext(ctx, dataSourcePayload)
 	if err != nil {
 		return nil, err
 	}
@@ -205,7 +526,7 @@ func (c *Client) CreateDataSource(dataSourcePayload *DataSource) (*DataSource, e
 	}
 
 	query := url.Values{}
-	response, err := c.post(path, string(payload), query)
+	response, err := c.postContext(ctx, path, string(payload), query)
 	if err != nil {
 		return nil, err
 	}
@@ -218,7 +539,7 @@ func (c *Client) CreateDataSource(dataSourcePayload *DataSource) (*DataSource, e
 
 	dataSource := DataSource{}
 
-	err = json.Unmarshal(body, &dataSource)
+	err = c.unmarshal(body, &dataSource)
 	if err != nil {
 		return nil, err
 	}
@@ -226,11 +547,29 @@ func (c *Client) CreateDataSource(dataSourcePayload *DataSource) (*DataSource, e
 	return &dataSource, nil
 }
 
-//UpdateDataSource Updates an existing DataSource
-func (c *Client) UpdateDataSource(id int, dataSourcePayload *DataSource) (*DataSource, error) {
-	path := "/api/data_sources/" + strconv.Itoa(id)
+// UpdateDataSource Updates an existing DataSource. When PreserveSecrets is
+// set, secret options still holding DataSourceSecretMask are left out
+func (c *Client) UpdateDataSource(id DataSourceID, dataSourcePayload *DataSource) (*DataSource, error) {
+	return c.UpdateDataSourceContext(context.Background(), id, dataSourcePayload)
+}
+
+// UpdateDataSourceContext is UpdateDataSource bound to the given context
+func (c *Client) UpdateDataSourceContext(ctx context.Context, id DataSourceID, dataSourcePayload *DataSource) (*DataSource, error) {
+	return c.updateDataSource(ctx, id, dataSourcePayload, c.PreservesSecrets())
+}
+
+func (c *Client) updateDataSource(ctx context.Context, id DataSourceID, dataSourcePayload *DataSource, preserveSecrets bool) (*DataSource, error) {
+	path := "/api/data_sources/" + id.String()
 
-	dataSourcePayload, err := c.SanitizeDataSourceOptions(dataSourcePayload)
+	if preserveSecrets && dataSourcePayload.Type != "" {
+		dataSourceType, err := c.GetDataSourceTypeContext(ctx, dataSourcePayload.Type)
+		if err != nil {
+			return nil, err
+		}
+		dataSourcePayload = StripMaskedSecrets(dataSourcePayload, dataSourceType)
+	}
+
+	dataSourcePayload, err := c.sanitizeDataSourceOptions(ctx, dataSourcePayload, preserveSecrets)
 	if err != nil {
 		return nil, err
 	}
@@ -241,7 +580,7 @@ func (c *Client) UpdateDataSource(id int, dataSourcePayload *DataSource) (*DataS
 	}
 
 	query := url.Values{}
-	response, err := c.post(path, string(payload), query)
+	response, err := c.postContext(ctx, path, string(payload), query)
 	if err != nil {
 		return nil, err
 	}
@@ -254,7 +593,7 @@ func (c *Client) UpdateDataSource(id int, dataSourcePayload *DataSource) (*DataS
 
 	dataSource := DataSource{}
 
-	err = json.Unmarshal(body, &dataSource)
+	err = c.unmarshal(body, &dataSource)
 	if err != nil {
 		return nil, err
 	}
@@ -262,15 +601,257 @@ func (c *Client) UpdateDataSource(id int, dataSourcePayload *DataSource) (*DataS
 	return &dataSource, nil
 }
 
+// PatchDataSource reads the current DataSource, applies the fields set in
+// the patch on top of it and writes the result back. Options are merged key
+// by key: options absent from the patch keep their current value, options
+// set to nil are removed, and masked secrets are never sent back
+func (c *Client) PatchDataSource(id DataSourceID, patch *DataSourcePatchPayload) (*DataSource, error) {
+	return c.PatchDataSourceContext(context.Background(), id, patch)
+}
+
+// PatchDataSourceContext is PatchDataSource bound to the given context
+func (c *Client) PatchDataSourceContext(ctx context.Context, id DataSourceID, patch *DataSourcePatchPayload) (*DataSource, error) {
+	current, err := c.GetDataSourceContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeDataSource(current, patch)
+
+	return c.updateDataSource(ctx, id, merged, true)
+}
+
+// mergeDataSource returns the DataSource to write back for a patch. Groups
+// are left out as they are managed through the group endpoints. DataSource
+// omits empty fields, so the fields the patch clears are sent through Extra
+func mergeDataSource(current *DataSource, patch *DataSourcePatchPayload) *DataSource {
+	merged := DataSource{
+		Name:               current.Name,
+		ScheduledQueueName: current.ScheduledQueueName,
+		QueueName:          current.QueueName,
+		Type:               current.Type,
+		Syntax:             current.Syntax,
+		Options:            map[string]interface{}{},
+		Extra:              map[string]json.RawMessage{},
+	}
+
+	for field, value := range current.Extra {
+		merged.Extra[field] = value
+	}
+	for field, value := range map[string]*string{
+		"name":                 patch.Name,
+		"scheduled_queue_name": patch.ScheduledQueueName,
+		"queue_name":           patch.QueueName,
+		"type":                 patch.Type,
+		"syntax":               patch.Syntax,
+	} {
+		if value != nil && *value == "" {
+			merged.Extra[field] = json.RawMessage(`""`)
+		}
+	}
+
+	for propName, propVal := range current.Options {
+		merged.Options[propName] = propVal
+	}
+	for propName, propVal := range patch.Options {
+		if propVal == nil {
+			delete(merged.Options, propName)
+			continue
+		}
+		merged.Options[propName] = propVal
+	}
+
+	if patch.Name != nil {
+		merged.Name = *patch.Name
+	}
+	if patch.ScheduledQueueName != nil {
+		merged.ScheduledQueueName = *patch.ScheduledQueueName
+	}
+	if patch.QueueName != nil {
+		merged.QueueName = *patch.QueueName
+	}
+	if patch.Type != nil {
+		merged.Type = *patch.Type
+	}
+	if patch.Syntax != nil {
+		merged.Syntax = *patch.Syntax
+	}
+
+	return &merged
+}
+
 //DeleteDataSource deletes a specific DataSource
-func (c *Client) DeleteDataSource(id int) error {
-	path := "/api/data_sources/" + strconv.Itoa(id)
+func (c *Client) DeleteDataSource(id DataSourceID) error {
+	return c.DeleteDataSourceContext(context.Background(), id)
+}
+
+// DeleteDataSourceContext is DeleteDataSource bound to the given context
+func (c *Client) DeleteDataSourceContext(ctx context.Context, id DataSourceID) error {
+	path := "/api/data_sources/" + id.String()
 
 	query := url.Values{}
-	_, err := c.delete(path, query)
+	response, err := c.deleteContext(ctx, path, query)
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 
 	return nil
 }
+
+// TestDataSource checks that Redash can connect to a DataSource
+func (c *Client) TestDataSource(id DataSourceID) (*DataSourceTestResult, error) {
+	return c.TestDataSourceContext(context.Background(), id)
+}
+
+// TestDataSourceContext is TestDataSource bound to the given context
+func (c *Client) TestDataSourceContext(ctx context.Context, id DataSourceID) (*DataSourceTestResult, error) {
+	path := "/api/data_sources/" + id.String() + "/test"
+
+	query := url.Values{}
+	response, err := c.postContext(ctx, path, "", query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := DataSourceTestResult{}
+
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetDataSourceSchema gets the tables and columns of a DataSource, asking
+// Redash to refresh its cached schema when refresh is set. Newer Redash
+// versions compute the schema in a background job, which is waited for up
+// to Config.JobTimeout
+func (c *Client) GetDataSourceSchema(id DataSourceID, refresh bool) ([]DataSourceSchemaTable, error) {
+	return c.GetDataSourceSchemaContext(context.Background(), id, refresh)
+}
+
+// GetDataSourceSchemaContext is GetDataSourceSchema bound to the given context
+func (c *Client) GetDataSourceSchemaContext(ctx context.Context, id DataSourceID, refresh bool) ([]DataSourceSchemaTable, error) {
+	path := "/api/data_sources/" + id.String() + "/schema"
+
+	query := url.Values{}
+	if refresh {
+		query.Add("refresh", "true")
+	}
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaResponse := dataSourceSchemaResponse{}
+	err = json.Unmarshal(body, &schemaResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if schemaResponse.Job != nil {
+		ctx, cancel := context.WithTimeout(ctx, c.jobTimeout())
+		defer cancel()
+
+		job, err := c.waitForJob(ctx, schemaResponse.Job.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		schemaResponse = dataSourceSchemaResponse{}
+		err = json.Unmarshal(job.Result, &schemaResponse.Schema)
+		if err != nil {
+			err = json.Unmarshal(job.Result, &schemaResponse)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if schemaResponse.Error != nil {
+		return nil, fmt.Errorf("Schema unavailable for data source %d: %s", id, schemaResponse.Error.Message)
+	}
+
+	return schemaResponse.Schema, nil
+}
+
+// PauseDataSource pauses a DataSource, stopping the execution of its queries
+func (c *Client) PauseDataSource(id DataSourceID, reason string) (*DataSource, error) {
+	return c.PauseDataSourceContext(context.Background(), id, reason)
+}
+
+// PauseDataSourceContext is PauseDataSource bound to the given context
+func (c *Client) PauseDataSourceContext(ctx context.Context, id DataSourceID, reason string) (*DataSource, error) {
+	path := "/api/data_sources/" + id.String() + "/pause"
+
+	payload, err := json.Marshal(DataSourcePausePayload{Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	response, err := c.postContext(ctx, path, string(payload), query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	dataSource := DataSource{}
+
+	err = c.unmarshal(body, &dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataSource, nil
+}
+
+// ResumeDataSource resumes a paused DataSource
+func (c *Client) ResumeDataSource(id DataSourceID) (*DataSource, error) {
+	return c.ResumeDataSourceContext(context.Background(), id)
+}
+
+// ResumeDataSourceContext is ResumeDataSource bound to the given context
+func (c *Client) ResumeDataSourceContext(ctx context.Context, id DataSourceID) (*DataSource, error) {
+	path := "/api/data_sources/" + id.String() + "/pause"
+
+	query := url.Values{}
+	response, err := c.deleteContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	dataSource := DataSource{}
+
+	err = c.unmarshal(body, &dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataSource, nil
+}
@@ -0,0 +1,581 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const redshiftDataSourceTypes = `[{
+	"type": "redshift",
+	"name": "Redshift",
+	"configuration_schema": {
+		"secret": ["password"],
+		"required": ["dbname", "user", "password", "host", "port"],
+		"type": "object",
+		"order": ["host", "port", "user", "password", "dbname"],
+		"properties": {
+			"host": {"type": "string"},
+			"port": {"type": "number"},
+			"user": {"type": "string"},
+			"password": {"type": "string"},
+			"dbname": {"type": "string", "title": "Database Name"}
+		}
+	}
+}]`
+
+const redshiftDataSource = `{
+	"id": 1,
+	"name": "Warehouse",
+	"type": "redshift",
+	"syntax": "sql",
+	"options": {
+		"host": "old.acme.com",
+		"port": 5439,
+		"user": "redash",
+		"password": "--------",
+		"dbname": "warehouse"
+	}
+}`
+
+// capturePayload returns a responder which decodes the request body into
+// payload before answering with the given body
+func capturePayload(payload *DataSource, body string) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		raw, _ := ioutil.ReadAll(req.Body)
+		*payload = DataSource{}
+		if err := json.Unmarshal(raw, payload); err != nil {
+			return nil, err
+		}
+		return httpmock.NewStringResponse(200, body), nil
+	}
+}
+
+func TestGetDataSourceType(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/types",
+		httpmock.NewStringResponder(200, redshiftDataSourceTypes))
+
+	dataSourceType, err := c.GetDataSourceType("redshift")
+	assert.Nil(err)
+	assert.Equal("Redshift", dataSourceType.Name)
+	assert.True(dataSourceType.IsSecret("password"))
+	assert.False(dataSourceType.IsSecret("host"))
+
+	_, err = c.GetDataSourceType("unknown")
+	assert.NotNil(err)
+}
+
+func TestUpdateDataSourcePreservingSecrets(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", PreserveSecrets: true})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/types",
+		httpmock.NewStringResponder(200, redshiftDataSourceTypes))
+
+	var sent DataSource
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/1",
+		capturePayload(&sent, redshiftDataSource))
+
+	dataSource := DataSource{
+		Name: "Warehouse",
+		Type: "redshift",
+		Options: map[string]interface{}{
+			"host":     "new.acme.com",
+			"port":     5439,
+			"user":     "redash",
+			"password": DataSourceSecretMask,
+			"dbname":   "warehouse",
+		},
+	}
+
+	_, err := c.UpdateDataSource(1, &dataSource)
+	assert.Nil(err)
+	assert.Equal("new.acme.com", sent.Options["host"])
+	assert.NotContains(sent.Options, "password")
+	assert.Equal(DataSourceSecretMask, dataSource.Options["password"])
+}
+
+func TestStripMaskedSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	dataSourceType := &DataSourceType{Type: "redshift"}
+	dataSourceType.ConfigurationSchema.Secret = []string{"password"}
+	dataSource := &DataSource{
+		Name: "Warehouse",
+		Type: "redshift",
+		Options: map[string]interface{}{
+			"host":     "warehouse.acme.com",
+			"password": DataSourceSecretMask,
+		},
+	}
+
+	stripped := StripMaskedSecrets(dataSource, dataSourceType)
+	assert.Equal(map[string]interface{}{"host": "warehouse.acme.com"}, stripped.Options)
+	assert.Equal("Warehouse", stripped.Name)
+
+	// the caller's data source keeps its options
+	assert.Equal(map[string]interface{}{
+		"host":     "warehouse.acme.com",
+		"password": DataSourceSecretMask,
+	}, dataSource.Options)
+}
+
+func TestPatchDataSource(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/types",
+		httpmock.NewStringResponder(200, redshiftDataSourceTypes))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, redshiftDataSource))
+
+	var sent DataSource
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/1",
+		capturePayload(&sent, redshiftDataSource))
+
+	patch := DataSourcePatchPayload{
+		Options: map[string]interface{}{
+			"host": "new.acme.com",
+		},
+	}
+
+	_, err := c.PatchDataSource(1, &patch)
+	assert.Nil(err)
+	assert.Equal("Warehouse", sent.Name)
+	assert.Equal("redshift", sent.Type)
+	assert.Equal("new.acme.com", sent.Options["host"])
+	assert.Equal("warehouse", sent.Options["dbname"])
+	assert.Equal(float64(5439), sent.Options["port"])
+	assert.NotContains(sent.Options, "password")
+
+	patch = DataSourcePatchPayload{
+		Options: map[string]interface{}{
+			"password": "N3wS3cr3t",
+		},
+	}
+
+	_, err = c.PatchDataSource(1, &patch)
+	assert.Nil(err)
+	assert.Equal("N3wS3cr3t", sent.Options["password"])
+	assert.Equal("old.acme.com", sent.Options["host"])
+
+	patch = DataSourcePatchPayload{Name: String("Renamed")}
+
+	_, err = c.PatchDataSource(1, &patch)
+	assert.Nil(err)
+	assert.Equal("Renamed", sent.Name)
+	assert.Equal("old.acme.com", sent.Options["host"])
+
+	patch = DataSourcePatchPayload{QueueName: String("reports"), Syntax: String("custom")}
+
+	_, err = c.PatchDataSource(1, &patch)
+	assert.Nil(err)
+	assert.Equal("reports", sent.QueueName)
+	assert.Equal("custom", sent.Syntax)
+	assert.Equal("Warehouse", sent.Name)
+
+	// a pointer to an empty string clears the field rather than being omitted
+	var raw map[string]interface{}
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/1",
+		func(req *http.Request) (*http.Response, error) {
+			body, _ := ioutil.ReadAll(req.Body)
+			raw = nil
+			json.Unmarshal(body, &raw)
+			return httpmock.NewStringResponse(200, redshiftDataSource), nil
+		})
+	patch = DataSourcePatchPayload{QueueName: String(""), Syntax: String("custom")}
+
+	_, err = c.PatchDataSource(1, &patch)
+	assert.Nil(err)
+	assert.Equal("", raw["queue_name"])
+	assert.Equal("custom", raw["syntax"])
+	assert.NotContains(raw, "scheduled_queue_name")
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/1",
+		capturePayload(&sent, redshiftDataSource))
+
+	patch = DataSourcePatchPayload{
+		Options: map[string]interface{}{
+			"dbname": nil,
+		},
+	}
+
+	_, err = c.PatchDataSource(1, &patch)
+	assert.EqualError(err, "Required field missing: dbname")
+}
+
+func TestTestDataSource(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/1/test",
+		httpmock.NewStringResponder(200, `{"message": "success", "ok": true}`))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/2/test",
+		httpmock.NewStringResponder(200, `{"message": "could not connect to server", "ok": false}`))
+
+	result, err := c.TestDataSource(1)
+	assert.Nil(err)
+	assert.True(result.Ok)
+
+	result, err = c.TestDataSource(2)
+	assert.Nil(err)
+	assert.False(result.Ok)
+	assert.Equal("could not connect to server", result.Message)
+}
+
+func TestGetDataSourceSchema(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1/schema",
+		httpmock.NewStringResponder(200, `{"schema": [{"name": "public.events", "columns": ["id", "collector_tstamp"]}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/2/schema",
+		httpmock.NewStringResponder(200, `{"error": {"code": 1, "message": "Data source type does not support retrieving schema"}}`))
+
+	schema, err := c.GetDataSourceSchema(1, false)
+	assert.Nil(err)
+	assert.Equal([]DataSourceSchemaTable{{
+		Name: "public.events",
+		Columns: []DataSourceSchemaColumn{
+			{Name: "id"},
+			{Name: "collector_tstamp"},
+		},
+	}}, schema)
+
+	_, err = c.GetDataSourceSchema(2, false)
+	assert.NotNil(err)
+}
+
+func TestGetDataSourceSchemaJob(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1/schema?refresh=true",
+		httpmock.NewStringResponder(200, `{"job": {"id": "4f1b", "status": 1}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/4f1b",
+		sequenceResponder(
+			`{"job": {"id": "4f1b", "status": 2}}`,
+			`{"job": {"id": "4f1b", "status": 3, "result": [{"name": "public.events", "columns": [{"name": "id", "type": "integer"}]}]}}`,
+		))
+
+	schema, err := c.GetDataSourceSchema(1, true)
+	assert.Nil(err)
+	assert.Equal([]DataSourceSchemaTable{{
+		Name:    "public.events",
+		Columns: []DataSourceSchemaColumn{{Name: "id", Type: "integer"}},
+	}}, schema)
+	assert.Equal(2, httpmock.GetCallCountInfo()["GET https://com.acme/api/jobs/4f1b"])
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/4f1b",
+		httpmock.NewStringResponder(200, `{"job": {"id": "4f1b", "status": 4, "error": "timeout"}}`))
+
+	_, err = c.GetDataSourceSchema(1, true)
+	assert.NotNil(err)
+}
+
+func TestPauseState(t *testing.T) {
+	assert := assert.New(t)
+
+	for body, paused := range map[string]PauseState{
+		`{"paused": true}`:  1,
+		`{"paused": 1}`:     1,
+		`{"paused": false}`: 0,
+		`{"paused": 0}`:     0,
+		`{"paused": null}`:  0,
+		`{}`:                0,
+	} {
+		dataSource := DataSource{}
+		err := json.Unmarshal([]byte(body), &dataSource)
+		assert.Nil(err)
+		assert.Equal(paused, dataSource.Paused, body)
+	}
+
+	err := json.Unmarshal([]byte(`{"paused": "yes"}`), &DataSource{})
+	assert.NotNil(err)
+
+	// write payloads never carry the pause state
+	payload, err := json.Marshal(DataSource{Name: "Warehouse"})
+	assert.Nil(err)
+	assert.NotContains(string(payload), "paused")
+
+	payload, err = json.Marshal(DataSource{Name: "Warehouse", Paused: 1, PauseReason: "Maintenance"})
+	assert.Nil(err)
+	assert.JSONEq(`{"name": "Warehouse"}`, string(payload))
+}
+
+func TestPauseResumeDataSource(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	var reason DataSourcePausePayload
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/1/pause",
+		func(req *http.Request) (*http.Response, error) {
+			raw, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(raw, &reason)
+			return httpmock.NewStringResponse(200, `{"id": 1, "paused": 1, "pause_reason": "Warehouse upgrade"}`), nil
+		})
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/data_sources/1/pause",
+		httpmock.NewStringResponder(200, `{"id": 1, "paused": 0, "pause_reason": null}`))
+
+	dataSource, err := c.PauseDataSource(1, "Warehouse upgrade")
+	assert.Nil(err)
+	assert.Equal("Warehouse upgrade", reason.Reason)
+	assert.Equal(PauseState(1), dataSource.Paused)
+	assert.Equal("Warehouse upgrade", dataSource.PauseReason)
+
+	dataSource, err = c.ResumeDataSource(1)
+	assert.Nil(err)
+	assert.Equal(PauseState(0), dataSource.Paused)
+}
+
+func TestPauseDataSources(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, `{"id": 1, "paused": 0}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/2",
+		httpmock.NewStringResponder(200, `{"id": 2, "paused": 1, "pause_reason": "Broken credentials"}`))
+
+	reasons := map[string][]string{}
+	for _, id := range []string{"1", "2"} {
+		id := id
+		httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/"+id+"/pause",
+			func(req *http.Request) (*http.Response, error) {
+				payload := DataSourcePausePayload{}
+				raw, _ := ioutil.ReadAll(req.Body)
+				json.Unmarshal(raw, &payload)
+				reasons[id] = append(reasons[id], payload.Reason)
+				return httpmock.NewStringResponse(200, `{"id": `+id+`, "paused": 1}`), nil
+			})
+		httpmock.RegisterResponder("DELETE", "https://com.acme/api/data_sources/"+id+"/pause",
+			httpmock.NewStringResponder(200, `{"id": `+id+`, "paused": 0}`))
+	}
+
+	maintenance, err := c.PauseDataSources([]DataSourceID{1, 2}, "Maintenance")
+	assert.Nil(err)
+	assert.Equal([]string{"Maintenance"}, reasons["1"])
+	assert.Equal([]string{"Maintenance"}, reasons["2"])
+
+	err = maintenance.Restore()
+	assert.Nil(err)
+	assert.Equal(1, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/data_sources/1/pause"])
+	assert.Equal(0, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/data_sources/2/pause"])
+	assert.Equal([]string{"Maintenance", "Broken credentials"}, reasons["2"])
+
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/2/pause",
+		httpmock.NewStringResponder(500, ""))
+
+	_, err = c.PauseDataSources([]DataSourceID{1, 2}, "Maintenance")
+	assert.NotNil(err)
+	assert.Equal(2, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/data_sources/1/pause"])
+}
+
+func TestSafeDeleteDataSource(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 2, "page": 1, "page_size": 250, "results": [{"id": 5, "data_source_id": 1, "version": 4}, {"id": 6, "data_source_id": 2}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/archive?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 2, "page": 1, "page_size": 250, "results": [{"id": 7, "data_source_id": 1, "is_archived": true}, {"id": 8, "data_source_id": 2, "is_archived": true}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 2, "page": 1, "page_size": 250, "results": [{"id": 1}, {"id": 2}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards/1",
+		httpmock.NewStringResponder(200, salesDashboard))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards/2",
+		httpmock.NewStringResponder(200, `{"id": 2, "name": "Empty", "widgets": []}`))
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(204, ""))
+
+	dependencies, err := c.GetDataSourceDependencies(1)
+	assert.Nil(err)
+	assert.Len(dependencies.Queries, 1)
+	assert.Len(dependencies.ArchivedQueries, 1)
+	assert.Equal(QueryID(7), dependencies.ArchivedQueries[0].ID)
+	assert.Len(dependencies.Dashboards, 1)
+	assert.Equal("Sales", dependencies.Dashboards[0].Name)
+
+	err = c.SafeDeleteDataSource(1, nil)
+	inUse, ok := err.(*DataSourceInUseError)
+	assert.True(ok)
+	assert.Equal(QueryID(5), inUse.Dependencies.Queries[0].ID)
+	assert.EqualError(err, "Data source 1 is used by 1 queries, 1 archived queries and 1 dashboards")
+	assert.Equal(0, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/data_sources/1"])
+
+	err = c.SafeDeleteDataSource(1, &DeleteDataSourceOptions{Force: true})
+	assert.Nil(err)
+	assert.Equal(1, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/data_sources/1"])
+
+	// archived queries alone still block the deletion
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 0, "page": 1, "page_size": 250, "results": []}`))
+	err = c.SafeDeleteDataSource(1, nil)
+	assert.EqualError(err, "Data source 1 is used by 0 queries, 1 archived queries and 0 dashboards")
+
+	// a dashboard which cannot be read fails the lookup
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 1, "page": 1, "page_size": 250, "results": [{"id": 5, "data_source_id": 1}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards/2",
+		httpmock.NewStringResponder(500, ""))
+	_, err = c.GetDataSourceDependencies(1)
+	assert.EqualError(err, "HTTP Response: 500")
+}
+
+func TestSafeDeleteDataSourceWithReplacement(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 1, "page": 1, "page_size": 250, "results": [{"id": 5, "data_source_id": 1, "version": 4}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/archive?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 1, "page": 1, "page_size": 250, "results": [{"id": 7, "data_source_id": 1, "version": 2, "is_archived": true}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/dashboards?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 0, "page": 1, "page_size": 250, "results": []}`))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/7",
+		httpmock.NewStringResponder(200, `{"id": 7, "data_source_id": 3, "version": 3}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/3",
+		httpmock.NewStringResponder(200, `{"id": 3}`))
+
+	var moved map[string]interface{}
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/5",
+		func(req *http.Request) (*http.Response, error) {
+			raw, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(raw, &moved)
+			return httpmock.NewStringResponse(200, `{"id": 5, "data_source_id": 3, "version": 5}`), nil
+		})
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(204, ""))
+
+	err := c.SafeDeleteDataSource(1, &DeleteDataSourceOptions{ReplacementID: 3})
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"data_source_id": float64(3), "version": float64(4)}, moved)
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/7"])
+	assert.Equal(1, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/data_sources/1"])
+
+	err = c.SafeDeleteDataSource(1, &DeleteDataSourceOptions{ReplacementID: 1})
+	assert.NotNil(err)
+}
+
+func TestGetDataSourceByName(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources",
+		httpmock.NewStringResponder(200, `[{"id": 1, "name": "Warehouse"}, {"id": 2, "name": "Replica"}, {"id": 3, "name": "Replica"}]`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, redshiftDataSource))
+
+	dataSource, err := c.GetDataSourceByName("Warehouse")
+	assert.Nil(err)
+	assert.Equal("old.acme.com", dataSource.Options["host"])
+
+	_, err = c.GetDataSourceByName("Missing")
+	assert.True(errors.Is(err, ErrNotFound))
+
+	_, err = c.GetDataSourceByName("Replica")
+	assert.True(errors.Is(err, ErrAmbiguous))
+}
+
+func TestEnsureDataSource(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources",
+		httpmock.NewStringResponder(200, `[{"id": 1, "name": "Warehouse"}]`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/types",
+		httpmock.NewStringResponder(200, redshiftDataSourceTypes))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, redshiftDataSource))
+
+	var sent DataSource
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/1",
+		capturePayload(&sent, redshiftDataSource))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources",
+		capturePayload(&sent, `{"id": 2, "name": "Replica"}`))
+
+	desired := DataSource{
+		Name: "Warehouse",
+		Type: "redshift",
+		Options: map[string]interface{}{
+			"host":     "old.acme.com",
+			"port":     5439,
+			"user":     "redash",
+			"password": "S3cuR3PaSsW0rD",
+			"dbname":   "warehouse",
+		},
+	}
+
+	_, err := c.EnsureDataSource(&desired)
+	assert.Nil(err)
+	assert.Equal(0, httpmock.GetCallCountInfo()["POST https://com.acme/api/data_sources/1"])
+
+	desired.Options["host"] = "new.acme.com"
+	_, err = c.EnsureDataSource(&desired)
+	assert.Nil(err)
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/data_sources/1"])
+	assert.Equal("new.acme.com", sent.Options["host"])
+
+	desired.Name = "Replica"
+	dataSource, err := c.EnsureDataSource(&desired)
+	assert.Nil(err)
+	assert.Equal(DataSourceID(2), dataSource.ID)
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/data_sources"])
+}
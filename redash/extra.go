@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownFields is wrapped by the errors returned under
+// Config.StrictDecoding when Redash sends fields the client does not declare
+var ErrUnknownFields = errors.New("Unknown fields")
+
+// extraCarrier is implemented by resources which keep the fields they do not
+// declare in an Extra map
+type extraCarrier interface {
+	extraFields() map[string]json.RawMessage
+}
+
+// unmarshalWithExtra decodes data into v, a pointer to a struct, and returns
+// the fields of data which the struct does not declare
+func unmarshalWithExtra(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range jsonFieldNames(reflect.TypeOf(v).Elem()) {
+		for field := range fields {
+			if strings.EqualFold(field, name) {
+				delete(fields, field)
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return fields, nil
+}
+
+// marshalWithExtra encodes v, a struct, adding the extra fields it does not
+// already hold
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	for field, value := range extra {
+		if _, exists := fields[field]; !exists {
+			fields[field] = value
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// jsonFieldNames returns the JSON names of the fields of a struct type
+func jsonFieldNames(t reflect.Type) []string {
+	names := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch tag {
+		case "-":
+			continue
+		case "":
+			names = append(names, field.Name)
+		default:
+			names = append(names, tag)
+		}
+	}
+
+	return names
+}
+
+// unmarshal decodes a response body into v. With Config.StrictDecoding set,
+// it fails when any resource decoded carries undeclared fields
+func (c *Client) unmarshal(body []byte, v interface{}) error {
+	err := json.Unmarshal(body, v)
+	if err != nil {
+		return err
+	}
+
+	if !c.Config.StrictDecoding {
+		return nil
+	}
+
+	return checkUnknownFields(reflect.ValueOf(v))
+}
+
+func checkUnknownFields(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return checkUnknownFields(v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkUnknownFields(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		if carrier, ok := v.Interface().(extraCarrier); ok {
+			if extra := carrier.extraFields(); len(extra) > 0 {
+				fields := []string{}
+				for field := range extra {
+					fields = append(fields, field)
+				}
+				sort.Strings(fields)
+
+				return fmt.Errorf("%s has %s: %w", v.Type().Name(), strings.Join(fields, ", "), ErrUnknownFields)
+			}
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if err := checkUnknownFields(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtraFieldsRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	group := Group{}
+	err := json.Unmarshal([]byte(`{"id": 1, "name": "admin", "permissions": ["admin"], "member_count": 3, "sso_mapping": {"claim": "admins"}}`), &group)
+	assert.Nil(err)
+	assert.Equal("admin", group.Name)
+	assert.Equal(map[string]json.RawMessage{
+		"member_count": json.RawMessage(`3`),
+		"sso_mapping":  json.RawMessage(`{"claim": "admins"}`),
+	}, group.Extra)
+
+	payload, err := json.Marshal(group)
+	assert.Nil(err)
+	assert.JSONEq(`{"id": 1, "name": "admin", "permissions": ["admin"], "created_at": "0001-01-01T00:00:00Z", "member_count": 3, "sso_mapping": {"claim": "admins"}}`, string(payload))
+
+	user := User{}
+	err = json.Unmarshal([]byte(`{"id": 1, "email": "jane@acme.com", "api_key": "k3y"}`), &user)
+	assert.Nil(err)
+	assert.Equal(json.RawMessage(`"k3y"`), user.Extra["api_key"])
+
+	dataSource := DataSource{}
+	err = json.Unmarshal([]byte(`{"id": 1, "type": "pg", "view_only": false}`), &dataSource)
+	assert.Nil(err)
+	assert.Equal(json.RawMessage(`false`), dataSource.Extra["view_only"])
+	assert.NotContains(dataSource.Extra, "type")
+
+	payload, err = json.Marshal(&dataSource)
+	assert.Nil(err)
+	assert.Contains(string(payload), `"view_only":false`)
+}
+
+func TestUpdateGroupPreservesExtraFields(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/groups/1",
+		httpmock.NewStringResponder(200, `{"id": 1, "name": "Existing Group", "sso_mapping": "analysts"}`))
+
+	var sent map[string]interface{}
+	httpmock.RegisterResponder("POST", "https://com.acme/api/groups/1",
+		func(req *http.Request) (*http.Response, error) {
+			raw, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(raw, &sent)
+			return httpmock.NewStringResponse(200, string(raw)), nil
+		})
+
+	group, err := c.GetGroup(1)
+	assert.Nil(err)
+
+	group.Name = "Renamed Group"
+	group, err = c.UpdateGroup(1, group)
+	assert.Nil(err)
+	assert.Equal("Renamed Group", sent["name"])
+	assert.Equal("analysts", sent["sso_mapping"])
+	assert.Equal(json.RawMessage(`"analysts"`), group.Extra["sso_mapping"])
+}
+
+func TestStrictDecoding(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", StrictDecoding: true})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/groups",
+		httpmock.NewStringResponder(200, `[{"id": 1, "name": "admin"}, {"id": 2, "name": "default", "sso_mapping": "all"}]`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users/1",
+		httpmock.NewStringResponder(200, `{"id": 1, "name": "Existing User"}`))
+
+	_, err := c.GetGroups()
+	assert.True(errors.Is(err, ErrUnknownFields))
+	assert.Contains(err.Error(), "sso_mapping")
+
+	_, err = c.GetUser(1)
+	assert.Nil(err)
+}
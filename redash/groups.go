@@ -14,10 +14,12 @@
 package redash
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/url"
-	"strconv"
 	"time"
 )
 
@@ -26,18 +28,44 @@ type Group struct {
 	CreatedAt   time.Time `json:"created_at,omitempty"`
 	Permissions []string  `json:"permissions,omitempty"`
 	Type        string    `json:"type,omitempty"`
-	ID          int       `json:"id,omitempty"`
+	ID          GroupID   `json:"id,omitempty"`
 	Name        string    `json:"name,omitempty"`
+
+	// Extra holds the fields Redash sent which Group does not declare
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the fields Redash sends which Group does not declare
+// in Extra
+func (g *Group) UnmarshalJSON(data []byte) error {
+	type group Group
+	extra, err := unmarshalWithExtra(data, (*group)(g))
+	if err != nil {
+		return err
+	}
+
+	g.Extra = extra
+	return nil
+}
+
+// MarshalJSON sends the fields kept in Extra back along with the declared ones
+func (g Group) MarshalJSON() ([]byte, error) {
+	type group Group
+	return marshalWithExtra(group(g), g.Extra)
+}
+
+func (g Group) extraFields() map[string]json.RawMessage {
+	return g.Extra
 }
 
 // GroupUser struct
 type GroupUser struct {
-	MemberID int `json:"user_id"`
+	MemberID UserID `json:"user_id"`
 }
 
 // GroupDataSource struct
 type GroupDataSource struct {
-	DataSourceID int `json:"data_source_id"`
+	DataSourceID DataSourceID `json:"data_source_id"`
 }
 
 // GroupCreatePayload struct
@@ -45,12 +73,23 @@ type GroupCreatePayload struct {
 	Name string `json:"name"`
 }
 
+// GroupPatchPayload struct for partially mutating groups. Only the fields
+// set are sent
+type GroupPatchPayload struct {
+	Name *string `json:"name,omitempty"`
+}
+
 // GetGroups returns a list of Redash groups
 func (c *Client) GetGroups() (*[]Group, error) {
+	return c.GetGroupsContext(context.Background())
+}
+
+// GetGroupsContext is GetGroups bound to the given context
+func (c *Client) GetGroupsContext(ctx context.Context) (*[]Group, error) {
 	path := "/api/groups"
 
 	query := url.Values{}
-	response, err := c.get(path, query)
+	response, err := c.getContext(ctx, path, query)
 
 	if err != nil {
 		return nil, err
@@ -59,7 +98,7 @@ func (c *Client) GetGroups() (*[]Group, error) {
 	body, _ := ioutil.ReadAll(response.Body)
 
 	groups := []Group{}
-	err = json.Unmarshal(body, &groups)
+	err = c.unmarshal(body, &groups)
 	if err != nil {
 		return nil, err
 	}
@@ -68,11 +107,16 @@ func (c *Client) GetGroups() (*[]Group, error) {
 }
 
 // GetGroup returns an individual Redash group
-func (c *Client) GetGroup(id int) (*Group, error) {
-	path := "/api/groups/" + strconv.Itoa(id)
+func (c *Client) GetGroup(id GroupID) (*Group, error) {
+	return c.GetGroupContext(context.Background(), id)
+}
+
+// GetGroupContext is GetGroup bound to the given context
+func (c *Client) GetGroupContext(ctx context.Context, id GroupID) (*Group, error) {
+	path := "/api/groups/" + id.String()
 
 	query := url.Values{}
-	response, err := c.get(path, query)
+	response, err := c.getContext(ctx, path, query)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +129,7 @@ func (c *Client) GetGroup(id int) (*Group, error) {
 
 	group := Group{}
 
-	err = json.Unmarshal(body, &group)
+	err = c.unmarshal(body, &group)
 	if err != nil {
 		return nil, err
 	}
@@ -93,8 +137,59 @@ func (c *Client) GetGroup(id int) (*Group, error) {
 	return &group, nil
 }
 
+// GetGroupByName returns the Redash group with the given name. The error
+// wraps ErrNotFound or ErrAmbiguous when no group or several groups match
+func (c *Client) GetGroupByName(name string) (*Group, error) {
+	return c.GetGroupByNameContext(context.Background(), name)
+}
+
+// GetGroupByNameContext is GetGroupByName bound to the given context
+func (c *Client) GetGroupByNameContext(ctx context.Context, name string) (*Group, error) {
+	groups, err := c.GetGroupsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []Group{}
+	for _, group := range *groups {
+		if group.Name == name {
+			matches = append(matches, group)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("No group named %q: %w", name, ErrNotFound)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("%d groups named %q: %w", len(matches), name, ErrAmbiguous)
+	}
+}
+
+// EnsureGroup returns the Redash group with the given name, creating it
+// when missing
+func (c *Client) EnsureGroup(name string) (*Group, error) {
+	return c.EnsureGroupContext(context.Background(), name)
+}
+
+// EnsureGroupContext is EnsureGroup bound to the given context
+func (c *Client) EnsureGroupContext(ctx context.Context, name string) (*Group, error) {
+	group, err := c.GetGroupByNameContext(ctx, name)
+	if errors.Is(err, ErrNotFound) {
+		return c.CreateGroupContext(ctx, &GroupCreatePayload{Name: name})
+	}
+
+	return group, err
+}
+
 // CreateGroup creates a new Redash group
 func (c *Client) CreateGroup(groupPayload *GroupCreatePayload) (*Group, error) {
+	return c.CreateGroupContext(context.Background(), groupPayload)
+}
+
+// CreateGroupContext is CreateGroup bound to the given context
+func (c *Client) CreateGroupContext(ctx context.Context, groupPayload *GroupCreatePayload) (*Group, error) {
 	path := "/api/groups"
 
 	payload, err := json.Marshal(groupPayload)
@@ -103,7 +198,7 @@ func (c *Client) CreateGroup(groupPayload *GroupCreatePayload) (*Group, error) {
 	}
 
 	query := url.Values{}
-	response, err := c.post(path, string(payload), query)
+	response, err := c.postContext(ctx, path, string(payload), query)
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +211,7 @@ func (c *Client) CreateGroup(groupPayload *GroupCreatePayload) (*Group, error) {
 
 	group := Group{}
 
-	err = json.Unmarshal(body, &group)
+	err = c.unmarshal(body, &group)
 	if err != nil {
 		return nil, err
 	}
@@ -125,8 +220,13 @@ func (c *Client) CreateGroup(groupPayload *GroupCreatePayload) (*Group, error) {
 }
 
 // UpdateGroup updates an existing Redash group
-func (c *Client) UpdateGroup(id int, group *Group) (*Group, error) {
-	path := "/api/groups/" + strconv.Itoa(id)
+func (c *Client) UpdateGroup(id GroupID, group *Group) (*Group, error) {
+	return c.UpdateGroupContext(context.Background(), id, group)
+}
+
+// UpdateGroupContext is UpdateGroup bound to the given context
+func (c *Client) UpdateGroupContext(ctx context.Context, id GroupID, group *Group) (*Group, error) {
+	path := "/api/groups/" + id.String()
 
 	payload, err := json.Marshal(group)
 	if err != nil {
@@ -134,7 +234,7 @@ func (c *Client) UpdateGroup(id int, group *Group) (*Group, error) {
 	}
 
 	query := url.Values{}
-	response, err := c.post(path, string(payload), query)
+	response, err := c.postContext(ctx, path, string(payload), query)
 	if err != nil {
 		return nil, err
 	}
@@ -145,7 +245,7 @@ func (c *Client) UpdateGroup(id int, group *Group) (*Group, error) {
 		return nil, err
 	}
 
-	err = json.Unmarshal(body, &group)
+	err = c.unmarshal(body, &group)
 	if err != nil {
 		return nil, err
 	}
@@ -153,22 +253,70 @@ func (c *Client) UpdateGroup(id int, group *Group) (*Group, error) {
 	return group, nil
 }
 
+// PatchGroup updates the fields of an existing Redash group which are set in
+// the payload, leaving the others untouched
+func (c *Client) PatchGroup(id GroupID, groupPatchPayload *GroupPatchPayload) (*Group, error) {
+	return c.PatchGroupContext(context.Background(), id, groupPatchPayload)
+}
+
+// PatchGroupContext is PatchGroup bound to the given context
+func (c *Client) PatchGroupContext(ctx context.Context, id GroupID, groupPatchPayload *GroupPatchPayload) (*Group, error) {
+	path := "/api/groups/" + id.String()
+
+	payload, err := json.Marshal(groupPatchPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	response, err := c.postContext(ctx, path, string(payload), query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	group := Group{}
+
+	err = c.unmarshal(body, &group)
+	if err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
 // DeleteGroup deletes a Redash group
-func (c *Client) DeleteGroup(id int) error {
-	path := "/api/groups/" + strconv.Itoa(id)
+func (c *Client) DeleteGroup(id GroupID) error {
+	return c.DeleteGroupContext(context.Background(), id)
+}
+
+// DeleteGroupContext is DeleteGroup bound to the given context
+func (c *Client) DeleteGroupContext(ctx context.Context, id GroupID) error {
+	path := "/api/groups/" + id.String()
 
 	query := url.Values{}
-	_, err := c.delete(path, query)
+	response, err := c.deleteContext(ctx, path, query)
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 
 	return nil
 }
 
 // GroupAddUser adds a user to a Redash group
-func (c *Client) GroupAddUser(groupID int, userID int) error {
-	path := "/api/groups/" + strconv.Itoa(groupID) + "/members"
+func (c *Client) GroupAddUser(groupID GroupID, userID UserID) error {
+	return c.GroupAddUserContext(context.Background(), groupID, userID)
+}
+
+// GroupAddUserContext is GroupAddUser bound to the given context
+func (c *Client) GroupAddUserContext(ctx context.Context, groupID GroupID, userID UserID) error {
+	path := "/api/groups/" + groupID.String() + "/members"
 
 	user := GroupUser{userID}
 	payload, err := json.Marshal(user)
@@ -177,7 +325,7 @@ func (c *Client) GroupAddUser(groupID int, userID int) error {
 	}
 
 	query := url.Values{}
-	response, err := c.post(path, string(payload), query)
+	response, err := c.postContext(ctx, path, string(payload), query)
 	if err != nil {
 		return err
 	}
@@ -187,11 +335,16 @@ func (c *Client) GroupAddUser(groupID int, userID int) error {
 }
 
 // GroupRemoveUser removes a user from a Redash group
-func (c *Client) GroupRemoveUser(groupID int, userID int) error {
-	path := "/api/groups/" + strconv.Itoa(groupID) + "/members/" + strconv.Itoa(userID)
+func (c *Client) GroupRemoveUser(groupID GroupID, userID UserID) error {
+	return c.GroupRemoveUserContext(context.Background(), groupID, userID)
+}
+
+// GroupRemoveUserContext is GroupRemoveUser bound to the given context
+func (c *Client) GroupRemoveUserContext(ctx context.Context, groupID GroupID, userID UserID) error {
+	path := "/api/groups/" + groupID.String() + "/members/" + userID.String()
 
 	query := url.Values{}
-	response, err := c.delete(path, query)
+	response, err := c.deleteContext(ctx, path, query)
 	if err != nil {
 		return err
 	}
@@ -201,8 +354,13 @@ func (c *Client) GroupRemoveUser(groupID int, userID int) error {
 }
 
 // GroupAddDataSource adds a Data Source to a Redash group
-func (c *Client) GroupAddDataSource(groupID int, dataSourceID int) error {
-	path := "/api/groups/" + strconv.Itoa(groupID) + "/data_sources"
+func (c *Client) GroupAddDataSource(groupID GroupID, dataSourceID DataSourceID) error {
+	return c.GroupAddDataSourceContext(context.Background(), groupID, dataSourceID)
+}
+
+// GroupAddDataSourceContext is GroupAddDataSource bound to the given context
+func (c *Client) GroupAddDataSourceContext(ctx context.Context, groupID GroupID, dataSourceID DataSourceID) error {
+	path := "/api/groups/" + groupID.String() + "/data_sources"
 
 	dataSource := GroupDataSource{dataSourceID}
 	payload, err := json.Marshal(dataSource)
@@ -211,7 +369,7 @@ func (c *Client) GroupAddDataSource(groupID int, dataSourceID int) error {
 	}
 
 	query := url.Values{}
-	response, err := c.post(path, string(payload), query)
+	response, err := c.postContext(ctx, path, string(payload), query)
 	if err != nil {
 		return err
 	}
@@ -221,11 +379,16 @@ func (c *Client) GroupAddDataSource(groupID int, dataSourceID int) error {
 }
 
 // GroupRemoveDataSource removes a Data Source from a Redash group
-func (c *Client) GroupRemoveDataSource(groupID int, dataSourceID int) error {
-	path := "/api/groups/" + strconv.Itoa(groupID) + "/data_sources/" + strconv.Itoa(dataSourceID)
+func (c *Client) GroupRemoveDataSource(groupID GroupID, dataSourceID DataSourceID) error {
+	return c.GroupRemoveDataSourceContext(context.Background(), groupID, dataSourceID)
+}
+
+// GroupRemoveDataSourceContext is GroupRemoveDataSource bound to the given context
+func (c *Client) GroupRemoveDataSourceContext(ctx context.Context, groupID GroupID, dataSourceID DataSourceID) error {
+	path := "/api/groups/" + groupID.String() + "/data_sources/" + dataSourceID.String()
 
 	query := url.Values{}
-	response, err := c.delete(path, query)
+	response, err := c.deleteContext(ctx, path, query)
 	if err != nil {
 		return err
 	}
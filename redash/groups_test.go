@@ -14,6 +14,9 @@
 package redash
 
 import (
+	"errors"
+	"io/ioutil"
+	"net/http"
 	"testing"
 
 	"github.com/jarcoal/httpmock"
@@ -35,7 +38,7 @@ func TestGetGroup(t *testing.T) {
 		panic(err.Error())
 	}
 
-	assert.Equal(1, group.ID)
+	assert.Equal(GroupID(1), group.ID)
 	assert.Equal("Existing Group", group.Name)
 }
 
@@ -58,6 +61,71 @@ func TestCreateGroup(t *testing.T) {
 		panic(err.Error())
 	}
 
-	assert.Equal(2, group.ID)
+	assert.Equal(GroupID(2), group.ID)
 	assert.Equal("New Group", group.Name)
 }
+
+func TestGetGroupByName(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/groups",
+		httpmock.NewStringResponder(200, `[{"id": 1, "name": "admin"}, {"id": 2, "name": "default"}, {"id": 3, "name": "analysts"}, {"id": 4, "name": "analysts"}]`))
+
+	group, err := c.GetGroupByName("default")
+	assert.Nil(err)
+	assert.Equal(GroupID(2), group.ID)
+
+	_, err = c.GetGroupByName("missing")
+	assert.True(errors.Is(err, ErrNotFound))
+
+	_, err = c.GetGroupByName("analysts")
+	assert.True(errors.Is(err, ErrAmbiguous))
+}
+
+func TestEnsureGroup(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/groups",
+		httpmock.NewStringResponder(200, `[{"id": 1, "name": "admin"}]`))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/groups",
+		httpmock.NewStringResponder(200, `{"id": 2, "name": "New Group"}`))
+
+	group, err := c.EnsureGroup("admin")
+	assert.Nil(err)
+	assert.Equal(GroupID(1), group.ID)
+	assert.Equal(0, httpmock.GetCallCountInfo()["POST https://com.acme/api/groups"])
+
+	group, err = c.EnsureGroup("New Group")
+	assert.Nil(err)
+	assert.Equal(GroupID(2), group.ID)
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/groups"])
+}
+
+func TestPatchGroup(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	var sent string
+	httpmock.RegisterResponder("POST", "https://com.acme/api/groups/2",
+		func(req *http.Request) (*http.Response, error) {
+			raw, _ := ioutil.ReadAll(req.Body)
+			sent = string(raw)
+			return httpmock.NewStringResponse(200, `{"id": 2, "name": "Renamed Group", "permissions": ["view_query"]}`), nil
+		})
+
+	group, err := c.PatchGroup(2, &GroupPatchPayload{Name: String("Renamed Group")})
+	assert.Nil(err)
+	assert.Equal(`{"name":"Renamed Group"}`, sent)
+	assert.Equal("Renamed Group", group.Name)
+}
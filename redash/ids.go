@@ -0,0 +1,124 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import "strconv"
+
+// Each kind of resource has its own id type, so the compiler rejects a user
+// id passed where a group id is expected. They are encoded as plain numbers.
+// Untyped constants convert implicitly, while int variables need converting,
+// e.g. redash.GroupID(id)
+
+// UserID identifies a User
+type UserID int
+
+// GroupID identifies a Group
+type GroupID int
+
+// DataSourceID identifies a DataSource
+type DataSourceID int
+
+// QueryID identifies a Query
+type QueryID int
+
+// QueryResultID identifies the stored result of a Query execution
+type QueryResultID int
+
+// DashboardID identifies a Dashboard
+type DashboardID int
+
+// WidgetID identifies a Widget
+type WidgetID int
+
+// VisualizationID identifies a Visualization
+type VisualizationID int
+
+// JobID identifies a Job. Unlike other ids, it is a string
+type JobID string
+
+// String returns the UserID in decimal, as used in API paths
+func (id UserID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+// String returns the GroupID in decimal, as used in API paths
+func (id GroupID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+// String returns the DataSourceID in decimal, as used in API paths
+func (id DataSourceID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+// String returns the QueryID in decimal, as used in API paths
+func (id QueryID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+// String returns the QueryResultID in decimal, as used in API paths
+func (id QueryResultID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+// String returns the DashboardID in decimal, as used in API paths
+func (id DashboardID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+// String returns the WidgetID in decimal, as used in API paths
+func (id WidgetID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+// String returns the VisualizationID in decimal, as used in API paths
+func (id VisualizationID) String() string {
+	return strconv.Itoa(int(id))
+}
+
+// String returns the JobID as used in API paths
+func (id JobID) String() string {
+	return string(id)
+}
+
+// The methods below keep the int signatures group memberships had before
+// typed ids, so callers can upgrade first and convert their ids afterwards
+
+// GroupAddUserByInt adds a user to a Redash group
+//
+// Deprecated: use GroupAddUser with a GroupID and a UserID
+func (c *Client) GroupAddUserByInt(groupID, userID int) error {
+	return c.GroupAddUser(GroupID(groupID), UserID(userID))
+}
+
+// GroupRemoveUserByInt removes a user from a Redash group
+//
+// Deprecated: use GroupRemoveUser with a GroupID and a UserID
+func (c *Client) GroupRemoveUserByInt(groupID, userID int) error {
+	return c.GroupRemoveUser(GroupID(groupID), UserID(userID))
+}
+
+// GroupAddDataSourceByInt adds a Data Source to a Redash group
+//
+// Deprecated: use GroupAddDataSource with a GroupID and a DataSourceID
+func (c *Client) GroupAddDataSourceByInt(groupID, dataSourceID int) error {
+	return c.GroupAddDataSource(GroupID(groupID), DataSourceID(dataSourceID))
+}
+
+// GroupRemoveDataSourceByInt removes a Data Source from a Redash group
+//
+// Deprecated: use GroupRemoveDataSource with a GroupID and a DataSourceID
+func (c *Client) GroupRemoveDataSourceByInt(groupID, dataSourceID int) error {
+	return c.GroupRemoveDataSource(GroupID(groupID), DataSourceID(dataSourceID))
+}
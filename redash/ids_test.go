@@ -0,0 +1,59 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDsEncodeAsNumbers(t *testing.T) {
+	assert := assert.New(t)
+
+	payload, err := json.Marshal(UserUpdatePayload{Groups: []GroupID{1, 2}})
+	assert.Nil(err)
+	assert.JSONEq(`{"name": "", "email": "", "group_ids": [1, 2]}`, string(payload))
+
+	member := GroupUser{}
+	err = json.Unmarshal([]byte(`{"user_id": 7}`), &member)
+	assert.Nil(err)
+	assert.Equal(UserID(7), member.MemberID)
+
+	assert.Equal("12", DataSourceID(12).String())
+	assert.Equal("query 3", fmt.Sprintf("query %d", QueryID(3)))
+	assert.Equal("query 3", fmt.Sprintf("query %v", QueryID(3)))
+}
+
+func TestGroupMembershipsByInt(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("POST", "https://com.acme/api/groups/2/members",
+		httpmock.NewStringResponder(200, `{}`))
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/groups/2/data_sources/5",
+		httpmock.NewStringResponder(200, `{}`))
+
+	groupID, userID, dataSourceID := 2, 1, 5
+	assert.Nil(c.GroupAddUserByInt(groupID, userID))
+	assert.Nil(c.GroupRemoveDataSourceByInt(groupID, dataSourceID))
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/groups/2/members"])
+	assert.Equal(1, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/groups/2/data_sources/5"])
+}
@@ -0,0 +1,206 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultJobPollInterval = time.Second
+	defaultJobTimeout      = 5 * time.Minute
+
+	// abandonedJobTimeout bounds the request cancelling a job nobody waits
+	// for anymore
+	abandonedJobTimeout = 10 * time.Second
+)
+
+// JobStatus is the state of a Job
+type JobStatus int
+
+// Job statuses as returned by Redash
+const (
+	JobPending   JobStatus = 1
+	JobStarted   JobStatus = 2
+	JobFinished  JobStatus = 3
+	JobFailed    JobStatus = 4
+	JobCancelled JobStatus = 5
+)
+
+var jobStatusNames = map[JobStatus]string{
+	JobPending:   "pending",
+	JobStarted:   "started",
+	JobFinished:  "finished",
+	JobFailed:    "failed",
+	JobCancelled: "cancelled",
+}
+
+func (s JobStatus) String() string {
+	if name, ok := jobStatusNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(s))
+}
+
+// Job is a background task run by Redash's workers, such as a query
+// execution. Error is set when it failed, and QueryResultID once a query
+// execution finished
+type Job struct {
+	ID            JobID           `json:"id"`
+	Status        JobStatus       `json:"status"`
+	Error         string          `json:"error,omitempty"`
+	QueryResultID QueryResultID   `json:"query_result_id,omitempty"`
+	Result        json.RawMessage `json:"result,omitempty"`
+}
+
+// Done reports whether the job is over, whether it finished, failed or was
+// cancelled
+func (j *Job) Done() bool {
+	return j.Status == JobFinished || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// outcome reports whether the job is over and, if so, the error it ended with
+func (j *Job) outcome() (bool, error) {
+	switch j.Status {
+	case JobFinished:
+		return true, nil
+	case JobFailed:
+		return true, fmt.Errorf("Job %s failed: %s", j.ID, j.Error)
+	case JobCancelled:
+		return true, fmt.Errorf("Job %s was cancelled", j.ID)
+	}
+
+	return false, nil
+}
+
+// jobResponse is the envelope Redash wraps jobs in
+type jobResponse struct {
+	Job Job `json:"job"`
+}
+
+func (c *Client) jobPollInterval() time.Duration {
+	if c.Config.JobPollInterval > 0 {
+		return c.Config.JobPollInterval
+	}
+
+	return defaultJobPollInterval
+}
+
+func (c *Client) jobTimeout() time.Duration {
+	if c.Config.JobTimeout > 0 {
+		return c.Config.JobTimeout
+	}
+
+	return defaultJobTimeout
+}
+
+// GetJob gets a specific Job
+func (c *Client) GetJob(id JobID) (*Job, error) {
+	return c.GetJobContext(context.Background(), id)
+}
+
+// GetJobContext is GetJob bound to the given context
+func (c *Client) GetJobContext(ctx context.Context, id JobID) (*Job, error) {
+	path := "/api/jobs/" + id.String()
+
+	query := url.Values{}
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	jobResponse := jobResponse{}
+	err = json.Unmarshal(body, &jobResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobResponse.Job, nil
+}
+
+// CancelJob asks Redash to cancel a Job. A job which is already over is left
+// as it is
+func (c *Client) CancelJob(id JobID) error {
+	return c.CancelJobContext(context.Background(), id)
+}
+
+// CancelJobContext is CancelJob bound to the given context
+func (c *Client) CancelJobContext(ctx context.Context, id JobID) error {
+	path := "/api/jobs/" + id.String()
+
+	query := url.Values{}
+	response, err := c.deleteContext(ctx, path, query)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+// abandonJob cancels a job whose waiter gave up, so it does not keep a
+// worker busy. The request outlives the waiter's context, keeping its values
+func (c *Client) abandonJob(ctx context.Context, id JobID) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abandonedJobTimeout)
+	defer cancel()
+
+	if err := c.CancelJobContext(ctx, id); err != nil {
+		c.logger().Warn("Could not cancel abandoned job", Field{"job_id", id}, Field{"error", err.Error()})
+		return
+	}
+	c.logger().Debug("Cancelled abandoned job", Field{"job_id", id})
+}
+
+// waitForJob polls a job until it succeeds, fails or the context is done,
+// cancelling it in the latter case
+func (c *Client) waitForJob(ctx context.Context, id JobID) (*Job, error) {
+	ticker := time.NewTicker(c.jobPollInterval())
+	defer ticker.Stop()
+
+	for {
+		job, err := c.GetJobContext(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.abandonJob(ctx, id)
+				return nil, fmt.Errorf("Job %s did not finish: %w", id, ctx.Err())
+			}
+			return nil, err
+		}
+
+		if done, err := job.outcome(); done {
+			if err != nil {
+				return nil, err
+			}
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			c.abandonJob(ctx, id)
+			return nil, fmt.Errorf("Job %s did not finish: %w", id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
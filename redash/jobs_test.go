@@ -0,0 +1,130 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetJob(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 4, "error": "relation \"events\" does not exist", "query_result_id": null}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/88cd",
+		httpmock.NewStringResponder(200, `{"job": {"id": "88cd", "status": 2}}`))
+
+	job, err := c.GetJob("77ab")
+	assert.Nil(err)
+	assert.Equal(JobID("77ab"), job.ID)
+	assert.Equal(JobFailed, job.Status)
+	assert.Equal("failed", job.Status.String())
+	assert.Equal(`relation "events" does not exist`, job.Error)
+	assert.True(job.Done())
+
+	job, err = c.GetJob("88cd")
+	assert.Nil(err)
+	assert.Equal(JobStarted, job.Status)
+	assert.False(job.Done())
+
+	assert.Equal("unknown (7)", JobStatus(7).String())
+}
+
+func TestCancelJob(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, ``))
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/jobs/88cd",
+		httpmock.NewStringResponder(403, `{"message": "Forbidden"}`))
+
+	assert.Nil(c.CancelJob("77ab"))
+	assert.EqualError(c.CancelJob("88cd"), "HTTP Response: 403")
+}
+
+func TestRunQueryCancelsJob(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 1}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 2}}`))
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, ``))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.RunQueryContext(ctx, 3, nil)
+	assert.EqualError(err, "Error running query 3: Job 77ab did not finish: context deadline exceeded")
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(1, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/jobs/77ab"])
+
+	// a job which fails is over, there is nothing to cancel
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 4, "error": "boom"}}`))
+	_, err = c.RunQuery(3, nil)
+	assert.NotNil(err)
+	assert.Equal(1, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/jobs/77ab"])
+}
+
+func TestRunQueriesCancelsJobs(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 1}}`))
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, ``))
+
+	// the batch is cancelled once the job is polled, so at least one run
+	// waits for it, while the other may not have submitted its query yet
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/77ab", func(*http.Request) (*http.Response, error) {
+		cancel()
+		return httpmock.NewStringResponse(200, `{"job": {"id": "77ab", "status": 1}}`), nil
+	})
+
+	// both runs share the job, which is cancelled once
+	report := c.RunQueriesContext(ctx, []BatchQuery{{QueryID: 3}, {QueryID: 3}}, 2)
+	assert.Len(report.Failed(), 2)
+	assert.Equal(1, httpmock.GetCallCountInfo()["DELETE https://com.acme/api/jobs/77ab"])
+}
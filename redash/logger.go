@@ -0,0 +1,123 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Field is a key/value pair attached to a log message
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger receives the messages logged by the client. Set Config.Logger to
+// one of the adapters below, or to your own implementation, to route them
+// into your application's logs; by default nothing is logged
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// NopLogger discards every message
+type NopLogger struct{}
+
+// Debug discards the message
+func (NopLogger) Debug(string, ...Field) {}
+
+// Info discards the message
+func (NopLogger) Info(string, ...Field) {}
+
+// Warn discards the message
+func (NopLogger) Warn(string, ...Field) {}
+
+// Error discards the message
+func (NopLogger) Error(string, ...Field) {}
+
+// slogLogger adapts a *slog.Logger to Logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to the given *slog.Logger, or to
+// slog.Default() when nil
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) log(level slog.Level, msg string, fields []Field) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		attrs = append(attrs, slog.Any(field.Key, field.Value))
+	}
+
+	l.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+func (l *slogLogger) Debug(msg string, fields ...Field) { l.log(slog.LevelDebug, msg, fields) }
+func (l *slogLogger) Info(msg string, fields ...Field)  { l.log(slog.LevelInfo, msg, fields) }
+func (l *slogLogger) Warn(msg string, fields ...Field)  { l.log(slog.LevelWarn, msg, fields) }
+func (l *slogLogger) Error(msg string, fields ...Field) { l.log(slog.LevelError, msg, fields) }
+
+// logrusLogger adapts a logrus.FieldLogger to Logger
+type logrusLogger struct {
+	logger logrus.FieldLogger
+}
+
+// NewLogrusLogger returns a Logger writing to the given logrus logger or
+// entry, or to the standard logrus logger when nil
+func NewLogrusLogger(logger logrus.FieldLogger) Logger {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+
+	return &logrusLogger{logger: logger}
+}
+
+func (l *logrusLogger) entry(fields []Field) logrus.FieldLogger {
+	if len(fields) == 0 {
+		return l.logger
+	}
+
+	logrusFields := make(logrus.Fields, len(fields))
+	for _, field := range fields {
+		logrusFields[field.Key] = field.Value
+	}
+
+	return l.logger.WithFields(logrusFields)
+}
+
+func (l *logrusLogger) Debug(msg string, fields ...Field) { l.entry(fields).Debug(msg) }
+func (l *logrusLogger) Info(msg string, fields ...Field)  { l.entry(fields).Info(msg) }
+func (l *logrusLogger) Warn(msg string, fields ...Field)  { l.entry(fields).Warn(msg) }
+func (l *logrusLogger) Error(msg string, fields ...Field) { l.entry(fields).Error(msg) }
+
+// logger returns the configured Logger, or a NopLogger when none is set
+func (c *Client) logger() Logger {
+	if c.Config.Logger != nil {
+		return c.Config.Logger
+	}
+
+	return NopLogger{}
+}
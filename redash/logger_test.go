@@ -0,0 +1,103 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+	assert.Equal(NopLogger{}, c.logger())
+}
+
+func TestSlogLogger(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	c, _ := NewClient(&Config{
+		RedashURI: "https://com.acme/",
+		APIKey:    "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		Logger:    NewSlogLogger(logger),
+	})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, redshiftDataSource))
+
+	_, err := c.GetDataSource(1)
+	assert.Nil(err)
+
+	record := map[string]interface{}{}
+	assert.Nil(json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal("DEBUG", record["level"])
+	assert.Equal("Redash request", record["msg"])
+	assert.Equal("GET", record["method"])
+	assert.Equal("/api/data_sources/1", record["path"])
+	assert.Equal(float64(200), record["status"])
+	assert.Contains(record, "duration")
+}
+
+func TestLogrusLogger(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	logger, hook := logrustest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	c, _ := NewClient(&Config{
+		RedashURI: "https://com.acme/",
+		APIKey:    "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		Logger:    NewLogrusLogger(logger),
+	})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/types",
+		httpmock.NewStringResponder(200, redshiftDataSourceTypes))
+
+	_, err := c.SanitizeDataSourceOptions(&DataSource{
+		Type: "redshift",
+		Options: map[string]interface{}{
+			"host":     "acme.com",
+			"port":     5439,
+			"user":     "redash",
+			"password": "hunter2",
+			"dbname":   "warehouse",
+			"colour":   "blue",
+		},
+	})
+	assert.Nil(err)
+
+	entry := hook.LastEntry()
+	assert.Equal(logrus.WarnLevel, entry.Level)
+	assert.Equal("Ignoring invalid field", entry.Message)
+	assert.Equal(logrus.Fields{"field": "colour", "type": "redshift"}, entry.Data)
+
+	assert.Equal(logrus.DebugLevel, hook.Entries[0].Level)
+	assert.Equal("GET", hook.Entries[0].Data["method"])
+	assert.Equal(200, hook.Entries[0].Data["status"])
+}
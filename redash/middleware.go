@@ -0,0 +1,202 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync/atomic"
+)
+
+// RequestIDHeader is the header RequestID sets
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware hooks into every request the client sends. Any of its fields
+// may be nil. BeforeRequest can change the request, e.g. to add headers or
+// sign it, and AfterResponse sees the response before its status is checked;
+// an error from either aborts the call with that error. RoundTripper wraps
+// the transport, to record metrics or inject faults
+type Middleware struct {
+	BeforeRequest func(*http.Request) error
+	AfterResponse func(*http.Response) error
+	RoundTripper  func(http.RoundTripper) http.RoundTripper
+}
+
+// RoundTripperFunc lets a function be used as an http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(request)
+func (f RoundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// Use appends middlewares to the client's chain. BeforeRequest hooks run in
+// the order they were added and AfterResponse hooks in reverse order, while
+// the first RoundTripper added is the outermost. Each RoundTripper wraps
+// the transport once, so wrappers may keep state across requests. Use may
+// be called while requests are in flight, which then run through the chain
+// as it was when they started or as it is now
+func (c *Client) Use(middlewares ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, middleware := range middlewares {
+		c.middlewares = append(c.middlewares, middleware)
+		if middleware.RoundTripper == nil {
+			continue
+		}
+
+		link := &transportLink{}
+		wrapped := middleware.RoundTripper(link)
+		if c.tail == nil {
+			c.transport = wrapped
+		} else {
+			c.tail.setNext(wrapped)
+		}
+		c.tail = link
+	}
+}
+
+// transportLink is the transport a RoundTripper middleware wraps. It sends
+// requests to the middlewares added after it, or to the base transport
+// when there are none, so adding a middleware does not wrap the transport
+// again
+type transportLink struct {
+	next atomic.Pointer[http.RoundTripper]
+}
+
+func (l *transportLink) setNext(next http.RoundTripper) {
+	l.next.Store(&next)
+}
+
+// RoundTrip sends the request to the next transport of the chain
+func (l *transportLink) RoundTrip(request *http.Request) (*http.Response, error) {
+	if next := l.next.Load(); next != nil {
+		return (*next).RoundTrip(request)
+	}
+
+	return baseTransport{}.RoundTrip(request)
+}
+
+// httpClient returns the client requests are sent with, its transport
+// wrapped by every RoundTripper middleware
+func (c *Client) httpClient() *http.Client {
+	c.mu.RLock()
+	transport := c.transport
+	c.mu.RUnlock()
+
+	if transport == nil {
+		transport = baseTransport{}
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// chain returns the middlewares added so far
+func (c *Client) chain() []Middleware {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.middlewares
+}
+
+func (c *Client) beforeRequest(request *http.Request) error {
+	for _, middleware := range c.chain() {
+		if middleware.BeforeRequest == nil {
+			continue
+		}
+		if err := middleware.BeforeRequest(request); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) afterResponse(response *http.Response) error {
+	middlewares := c.chain()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i].AfterResponse == nil {
+			continue
+		}
+		if err := middlewares[i].AfterResponse(response); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UserAgent sets the User-Agent header of every request
+func UserAgent(userAgent string) Middleware {
+	return Middleware{
+		BeforeRequest: func(request *http.Request) error {
+			request.Header.Set("User-Agent", userAgent)
+			return nil
+		},
+	}
+}
+
+// ExtraHeaders adds the given headers to every request, replacing any value
+// the client would otherwise send
+func ExtraHeaders(headers http.Header) Middleware {
+	return Middleware{
+		BeforeRequest: func(request *http.Request) error {
+			for name, values := range headers {
+				request.Header.Del(name)
+				for _, value := range values {
+					request.Header.Add(name, value)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context carrying the given request id, for
+// the RequestID middleware to send along
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id carried by the context, if any
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// RequestID sets the X-Request-ID header of every request to the id carried
+// by the request's context, or to a new random id when there is none, so
+// calls can be traced through Redash's logs
+func RequestID() Middleware {
+	return Middleware{
+		BeforeRequest: func(request *http.Request) error {
+			id, ok := RequestIDFromContext(request.Context())
+			if !ok {
+				random := make([]byte, 16)
+				if _, err := rand.Read(random); err != nil {
+					return err
+				}
+				id = hex.EncodeToString(random)
+			}
+
+			request.Header.Set(RequestIDHeader, id)
+			return nil
+		},
+	}
+}
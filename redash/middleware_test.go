@@ -0,0 +1,194 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// headerRecorder answers every request with the given body, recording the
+// headers it was sent with
+func headerRecorder(headers *http.Header, body string) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		*headers = req.Header.Clone()
+		return httpmock.NewStringResponse(200, body), nil
+	}
+}
+
+func TestBuiltinMiddlewares(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+	c.Use(
+		UserAgent("acme-sync/1.0"),
+		ExtraHeaders(http.Header{"X-Tenant": {"acme"}, "Content-Type": {"application/json; charset=utf-8"}}),
+		RequestID(),
+	)
+
+	var headers http.Header
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		headerRecorder(&headers, redshiftDataSource))
+
+	_, err := c.GetDataSource(1)
+	assert.Nil(err)
+	assert.Equal("acme-sync/1.0", headers.Get("User-Agent"))
+	assert.Equal("acme", headers.Get("X-Tenant"))
+	assert.Equal([]string{"application/json; charset=utf-8"}, headers.Values("Content-Type"))
+	assert.Equal("Key ApIkEyApIkEyApIkEyApIkEyApIkEy", headers.Get("Authorization"))
+	assert.Len(headers.Get(RequestIDHeader), 32)
+
+	ctx := ContextWithRequestID(context.Background(), "req-42")
+	_, err = c.GetDataSourceContext(ctx, 1)
+	assert.Nil(err)
+	assert.Equal("req-42", headers.Get(RequestIDHeader))
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	calls := []string{}
+	trace := func(name string) Middleware {
+		return Middleware{
+			BeforeRequest: func(*http.Request) error {
+				calls = append(calls, "before "+name)
+				return nil
+			},
+			AfterResponse: func(*http.Response) error {
+				calls = append(calls, "after "+name)
+				return nil
+			},
+			RoundTripper: func(next http.RoundTripper) http.RoundTripper {
+				return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					calls = append(calls, "enter "+name)
+					defer func() { calls = append(calls, "leave "+name) }()
+					return next.RoundTrip(req)
+				})
+			},
+		}
+	}
+	c.Use(trace("a"), trace("b"))
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, redshiftDataSource))
+
+	_, err := c.GetDataSource(1)
+	assert.Nil(err)
+	assert.Equal([]string{
+		"before a", "before b",
+		"enter a", "enter b", "leave b", "leave a",
+		"after b", "after a",
+	}, calls)
+}
+
+func TestMiddlewareTransportsBuiltOnce(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, redshiftDataSource))
+
+	// counting wraps its transport with a counter of its own, as a circuit
+	// breaker would keep its state
+	var built, sent int64
+	counting := Middleware{
+		RoundTripper: func(next http.RoundTripper) http.RoundTripper {
+			atomic.AddInt64(&built, 1)
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt64(&sent, 1)
+				return next.RoundTrip(req)
+			})
+		},
+	}
+	c.Use(counting)
+
+	// middlewares may be added while requests are in flight
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetDataSource(1)
+			assert.Nil(err)
+		}()
+	}
+	c.Use(UserAgent("acme/1.0"), counting)
+	wg.Wait()
+
+	assert.Equal(int64(2), atomic.LoadInt64(&built))
+
+	atomic.StoreInt64(&sent, 0)
+	_, err := c.GetDataSource(1)
+	assert.Nil(err)
+	assert.Equal(int64(2), atomic.LoadInt64(&sent))
+	assert.Equal(int64(2), atomic.LoadInt64(&built))
+}
+
+func TestMiddlewareErrors(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, redshiftDataSource))
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+	c.Use(Middleware{
+		RoundTripper: func(http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return httpmock.NewStringResponse(503, ""), nil
+			})
+		},
+	})
+
+	_, err := c.GetDataSource(1)
+	assert.EqualError(err, "HTTP Response: 503")
+	assert.Equal(0, httpmock.GetTotalCallCount())
+
+	unsigned := errors.New("Request could not be signed")
+	c, _ = NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+	c.Use(Middleware{
+		BeforeRequest: func(*http.Request) error { return unsigned },
+	})
+
+	_, err = c.GetDataSource(1)
+	assert.True(errors.Is(err, unsigned))
+	assert.Equal(0, httpmock.GetTotalCallCount())
+
+	rejected := errors.New("Response rejected")
+	c, _ = NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+	c.Use(Middleware{
+		AfterResponse: func(*http.Response) error { return rejected },
+	})
+
+	_, err = c.GetDataSource(1)
+	assert.True(errors.Is(err, rejected))
+	assert.Equal(1, httpmock.GetTotalCallCount())
+}
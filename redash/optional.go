@@ -0,0 +1,45 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+// Patch payloads only send the fields which are set: a nil pointer leaves a
+// field untouched, while a pointer to a zero value clears it. The helpers
+// below build such pointers inline
+
+// String returns a pointer to the given string
+func String(v string) *string {
+	return &v
+}
+
+// Int returns a pointer to the given int
+func Int(v int) *int {
+	return &v
+}
+
+// Bool returns a pointer to the given bool
+func Bool(v bool) *bool {
+	return &v
+}
+
+// DataSourceIDPtr returns a pointer to the given DataSourceID
+func DataSourceIDPtr(v DataSourceID) *DataSourceID {
+	return &v
+}
+
+// GroupIDs returns a pointer to a slice holding the given group ids, which
+// is empty rather than nil when none are given
+func GroupIDs(v ...GroupID) *[]GroupID {
+	ids := append([]GroupID{}, v...)
+	return &ids
+}
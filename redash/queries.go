@@ -0,0 +1,216 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxPageSize is the largest page Redash serves for paginated lists
+const maxPageSize = 250
+
+// QueryList struct
+type QueryList struct {
+	Count    int     `json:"count"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"page_size"`
+	Results  []Query `json:"results,omitempty"`
+}
+
+// Query struct
+type Query struct {
+	ID                QueryID                `json:"id,omitempty"`
+	Name              string                 `json:"name,omitempty"`
+	Description       string                 `json:"description,omitempty"`
+	Query             string                 `json:"query,omitempty"`
+	QueryHash         string                 `json:"query_hash,omitempty"`
+	DataSourceID      DataSourceID           `json:"data_source_id,omitempty"`
+	LatestQueryDataID QueryResultID          `json:"latest_query_data_id,omitempty"`
+	Schedule          *QuerySchedule         `json:"schedule,omitempty"`
+	Options           map[string]interface{} `json:"options,omitempty"`
+	Tags              []string               `json:"tags,omitempty"`
+	IsArchived        bool                   `json:"is_archived,omitempty"`
+	IsDraft           bool                   `json:"is_draft,omitempty"`
+	Version           int                    `json:"version,omitempty"`
+	CreatedAt         *time.Time             `json:"created_at,omitempty"`
+	UpdatedAt         *time.Time             `json:"updated_at,omitempty"`
+}
+
+// QuerySchedule struct
+type QuerySchedule struct {
+	Interval  int    `json:"interval,omitempty"`
+	Time      string `json:"time,omitempty"`
+	DayOfWeek string `json:"day_of_week,omitempty"`
+	Until     string `json:"until,omitempty"`
+}
+
+// UnmarshalJSON also accepts the plain string schedules of older Redash
+// versions, which hold either an interval in seconds or a daily time
+func (s *QuerySchedule) UnmarshalJSON(data []byte) error {
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*s = QuerySchedule{}
+		if interval, err := strconv.Atoi(legacy); err == nil {
+			s.Interval = interval
+		} else {
+			s.Time = legacy
+		}
+		return nil
+	}
+
+	type schedule QuerySchedule
+	return json.Unmarshal(data, (*schedule)(s))
+}
+
+// QueryUpdatePayload struct for mutating queries. Only the fields set are
+// sent. Version, when set, makes Redash refuse the update if the query
+// changed in the meantime
+type QueryUpdatePayload struct {
+	Name         *string       `json:"name,omitempty"`
+	Description  *string       `json:"description,omitempty"`
+	Query        *string       `json:"query,omitempty"`
+	DataSourceID *DataSourceID `json:"data_source_id,omitempty"`
+	Version      int           `json:"version,omitempty"`
+}
+
+// GetQueries returns a paginated list of queries, archived ones excluded
+func (c *Client) GetQueries(page, pageSize int) (*QueryList, error) {
+	return c.GetQueriesContext(context.Background(), page, pageSize)
+}
+
+// GetQueriesContext is GetQueries bound to the given context
+func (c *Client) GetQueriesContext(ctx context.Context, page, pageSize int) (*QueryList, error) {
+	path := "/api/queries"
+
+	query := url.Values{}
+	query.Add("page", strconv.Itoa(page))
+	query.Add("page_size", strconv.Itoa(pageSize))
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := QueryList{}
+	err = json.Unmarshal(body, &queries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queries, nil
+}
+
+// GetAllQueries returns every query, reading all pages of GetQueries
+func (c *Client) GetAllQueries() ([]Query, error) {
+	return c.GetAllQueriesContext(context.Background())
+}
+
+// GetAllQueriesContext is GetAllQueries bound to the given context
+func (c *Client) GetAllQueriesContext(ctx context.Context) ([]Query, error) {
+	queries := []Query{}
+
+	for page := 1; ; page++ {
+		queryList, err := c.GetQueriesContext(ctx, page, maxPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		queries = append(queries, queryList.Results...)
+		if len(queryList.Results) == 0 || len(queries) >= queryList.Count {
+			return queries, nil
+		}
+	}
+}
+
+// GetQuery gets a specific Query
+func (c *Client) GetQuery(id QueryID) (*Query, error) {
+	return c.GetQueryContext(context.Background(), id)
+}
+
+// GetQueryContext is GetQuery bound to the given context
+func (c *Client) GetQueryContext(ctx context.Context, id QueryID) (*Query, error) {
+	path := "/api/queries/" + id.String()
+
+	query := url.Values{}
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	redashQuery := Query{}
+
+	err = json.Unmarshal(body, &redashQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redashQuery, nil
+}
+
+// UpdateQuery updates an existing Query, dropping its results from the
+// client's ResultCache
+func (c *Client) UpdateQuery(id QueryID, queryUpdatePayload *QueryUpdatePayload) (*Query, error) {
+	return c.UpdateQueryContext(context.Background(), id, queryUpdatePayload)
+}
+
+// UpdateQueryContext is UpdateQuery bound to the given context
+func (c *Client) UpdateQueryContext(ctx context.Context, id QueryID, queryUpdatePayload *QueryUpdatePayload) (*Query, error) {
+	path := "/api/queries/" + id.String()
+
+	payload, err := json.Marshal(queryUpdatePayload)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	response, err := c.postContext(ctx, path, string(payload), query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	redashQuery := Query{}
+
+	err = json.Unmarshal(body, &redashQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.InvalidateQueryResults(id); err != nil {
+		c.logger().Warn("Could not invalidate query results", Field{"query_id", id}, Field{"error", err.Error()})
+	}
+
+	return &redashQuery, nil
+}
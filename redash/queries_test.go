@@ -0,0 +1,78 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetQuery(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/1",
+		httpmock.NewStringResponder(200, `{"id": 1, "name": "Events", "query": "SELECT 1", "data_source_id": 2, "schedule": {"interval": 3600, "until": null}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/2",
+		httpmock.NewStringResponder(200, `{"id": 2, "name": "Legacy", "schedule": "86400"}`))
+
+	query, err := c.GetQuery(1)
+	assert.Nil(err)
+	assert.Equal("Events", query.Name)
+	assert.Equal(DataSourceID(2), query.DataSourceID)
+	assert.Equal(3600, query.Schedule.Interval)
+
+	query, err = c.GetQuery(2)
+	assert.Nil(err)
+	assert.Equal(86400, query.Schedule.Interval)
+}
+
+func TestGetAllQueries(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries?page=1&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 3, "page": 1, "page_size": 250, "results": [{"id": 1}, {"id": 2}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries?page=2&page_size=250",
+		httpmock.NewStringResponder(200, `{"count": 3, "page": 2, "page_size": 250, "results": [{"id": 3}]}`))
+
+	queries, err := c.GetAllQueries()
+	assert.Nil(err)
+	assert.Len(queries, 3)
+	assert.Equal(QueryID(3), queries[2].ID)
+}
+
+func TestUpdateQuery(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/1",
+		httpmock.NewStringResponder(200, `{"id": 1, "data_source_id": 3, "version": 2}`))
+
+	query, err := c.UpdateQuery(1, &QueryUpdatePayload{DataSourceID: DataSourceIDPtr(3), Version: 1})
+	assert.Nil(err)
+	assert.Equal(DataSourceID(3), query.DataSourceID)
+	assert.Equal(2, query.Version)
+}
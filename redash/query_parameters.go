@@ -0,0 +1,343 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueryParameterType is the kind of a {{ parameter }} of a Query
+type QueryParameterType string
+
+// Parameter kinds supported by Redash
+const (
+	ParameterText                     QueryParameterType = "text"
+	ParameterNumber                   QueryParameterType = "number"
+	ParameterEnum                     QueryParameterType = "enum"
+	ParameterQuery                    QueryParameterType = "query"
+	ParameterDate                     QueryParameterType = "date"
+	ParameterDateTime                 QueryParameterType = "datetime-local"
+	ParameterDateTimeWithSeconds      QueryParameterType = "datetime-with-seconds"
+	ParameterDateRange                QueryParameterType = "date-range"
+	ParameterDateTimeRange            QueryParameterType = "datetime-range"
+	ParameterDateTimeRangeWithSeconds QueryParameterType = "datetime-range-with-seconds"
+)
+
+// dateLayouts are the formats Redash expects date parameters in
+var dateLayouts = map[QueryParameterType]string{
+	ParameterDate:                     "2006-01-02",
+	ParameterDateTime:                 "2006-01-02 15:04",
+	ParameterDateTimeWithSeconds:      "2006-01-02 15:04:05",
+	ParameterDateRange:                "2006-01-02",
+	ParameterDateTimeRange:            "2006-01-02 15:04",
+	ParameterDateTimeRangeWithSeconds: "2006-01-02 15:04:05",
+}
+
+// Dynamic dates and date ranges, resolved by Redash when the query runs
+var (
+	dynamicDates = map[string]bool{
+		"d_now":       true,
+		"d_yesterday": true,
+	}
+	dynamicDateRanges = map[string]bool{
+		"d_today":          true,
+		"d_yesterday":      true,
+		"d_this_week":      true,
+		"d_this_month":     true,
+		"d_this_year":      true,
+		"d_last_week":      true,
+		"d_last_month":     true,
+		"d_last_year":      true,
+		"d_last_7_days":    true,
+		"d_last_14_days":   true,
+		"d_last_30_days":   true,
+		"d_last_60_days":   true,
+		"d_last_90_days":   true,
+		"d_last_12_months": true,
+		"d_last_hour":      true,
+		"d_last_8_hours":   true,
+		"d_last_24_hours":  true,
+	}
+)
+
+// QueryParameterDefinition struct, as found in a Query's options. Value is
+// the default used when no value is given for the parameter
+type QueryParameterDefinition struct {
+	Name               string                    `json:"name"`
+	Title              string                    `json:"title,omitempty"`
+	Type               QueryParameterType        `json:"type"`
+	EnumOptions        string                    `json:"enumOptions,omitempty"`
+	QueryID            QueryID                   `json:"queryId,omitempty"`
+	MultiValuesOptions *QueryParameterMultiValue `json:"multiValuesOptions,omitempty"`
+	Value              interface{}               `json:"value,omitempty"`
+}
+
+// QueryParameterMultiValue struct describes how the values of a parameter
+// accepting several of them are joined
+type QueryParameterMultiValue struct {
+	Prefix    string `json:"prefix"`
+	Suffix    string `json:"suffix"`
+	Separator string `json:"separator"`
+}
+
+// Options returns the values an enum parameter accepts
+func (def *QueryParameterDefinition) Options() []string {
+	if def.EnumOptions == "" {
+		return nil
+	}
+
+	return strings.Split(def.EnumOptions, "\n")
+}
+
+// Parameters returns the definitions of the query's parameters
+func (q *Query) Parameters() ([]QueryParameterDefinition, error) {
+	raw, ok := q.Options["parameters"]
+	if !ok {
+		return []QueryParameterDefinition{}, nil
+	}
+
+	payload, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := []QueryParameterDefinition{}
+	err = json.Unmarshal(payload, &definitions)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid parameters for query %d: %v", q.ID, err)
+	}
+
+	return definitions, nil
+}
+
+// QueryParameterValue is the value of a query parameter. It is implemented by
+// TextValue, NumberValue, EnumValue, QueryDropdownValue, DateValue and
+// DateRangeValue
+type QueryParameterValue interface {
+	// encode checks the value suits the definition and returns it in the
+	// form Redash expects
+	encode(def *QueryParameterDefinition) (interface{}, error)
+}
+
+// QueryParameters maps parameter names to their values
+type QueryParameters map[string]QueryParameterValue
+
+// TextValue is the value of a text parameter
+type TextValue string
+
+func (v TextValue) encode(def *QueryParameterDefinition) (interface{}, error) {
+	if def.Type != ParameterText {
+		return nil, invalidParameterKind(def, "text")
+	}
+
+	return string(v), nil
+}
+
+// NumberValue is the value of a number parameter
+type NumberValue float64
+
+func (v NumberValue) encode(def *QueryParameterDefinition) (interface{}, error) {
+	if def.Type != ParameterNumber {
+		return nil, invalidParameterKind(def, "number")
+	}
+
+	return float64(v), nil
+}
+
+// EnumValue holds the values picked for an enum parameter. Several values
+// can only be given when the parameter accepts multiple values
+type EnumValue []string
+
+// Enum returns an EnumValue of the given values
+func Enum(values ...string) EnumValue {
+	return EnumValue(values)
+}
+
+func (v EnumValue) encode(def *QueryParameterDefinition) (interface{}, error) {
+	if def.Type != ParameterEnum {
+		return nil, invalidParameterKind(def, "enum")
+	}
+
+	options := map[string]bool{}
+	for _, option := range def.Options() {
+		options[option] = true
+	}
+	for _, value := range v {
+		if !options[value] {
+			return nil, fmt.Errorf("Invalid value for parameter %s: %q is not one of %s",
+				def.Name, value, strings.Join(def.Options(), ", "))
+		}
+	}
+
+	return encodeChoices(def, v)
+}
+
+// QueryDropdownValue holds the values picked for a query-based dropdown
+// parameter, whose options are the results of another query and so are not
+// checked. Several values can only be given when the parameter accepts
+// multiple values
+type QueryDropdownValue []string
+
+// QueryDropdown returns a QueryDropdownValue of the given values
+func QueryDropdown(values ...string) QueryDropdownValue {
+	return QueryDropdownValue(values)
+}
+
+func (v QueryDropdownValue) encode(def *QueryParameterDefinition) (interface{}, error) {
+	if def.Type != ParameterQuery {
+		return nil, invalidParameterKind(def, "query dropdown")
+	}
+
+	return encodeChoices(def, v)
+}
+
+func encodeChoices(def *QueryParameterDefinition, values []string) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("Missing value for parameter %s", def.Name)
+	}
+
+	if def.MultiValuesOptions != nil {
+		return append([]string{}, values...), nil
+	}
+
+	if len(values) > 1 {
+		return nil, fmt.Errorf("Parameter %s does not accept multiple values", def.Name)
+	}
+
+	return values[0], nil
+}
+
+// DateValue is the value of a date or date time parameter: either a fixed
+// time or a dynamic date such as "d_yesterday"
+type DateValue struct {
+	Time    time.Time
+	Dynamic string
+}
+
+// Date returns the DateValue of a fixed time
+func Date(t time.Time) DateValue {
+	return DateValue{Time: t}
+}
+
+// DynamicDate returns the DateValue of a dynamic date, e.g. "d_now"
+func DynamicDate(name string) DateValue {
+	return DateValue{Dynamic: name}
+}
+
+func (v DateValue) encode(def *QueryParameterDefinition) (interface{}, error) {
+	if def.Type != ParameterDate && def.Type != ParameterDateTime && def.Type != ParameterDateTimeWithSeconds {
+		return nil, invalidParameterKind(def, "date")
+	}
+
+	if v.Dynamic != "" {
+		if !dynamicDates[v.Dynamic] {
+			return nil, fmt.Errorf("Invalid value for parameter %s: unknown dynamic date %s", def.Name, v.Dynamic)
+		}
+		return v.Dynamic, nil
+	}
+
+	return v.Time.Format(dateLayouts[def.Type]), nil
+}
+
+// DateRangeValue is the value of a date or date time range parameter:
+// either fixed bounds or a dynamic range such as "d_last_7_days"
+type DateRangeValue struct {
+	Start   time.Time
+	End     time.Time
+	Dynamic string
+}
+
+// DateRange returns the DateRangeValue between two fixed times
+func DateRange(start, end time.Time) DateRangeValue {
+	return DateRangeValue{Start: start, End: end}
+}
+
+// DynamicDateRange returns the DateRangeValue of a dynamic range, e.g.
+// "d_last_7_days"
+func DynamicDateRange(name string) DateRangeValue {
+	return DateRangeValue{Dynamic: name}
+}
+
+func (v DateRangeValue) encode(def *QueryParameterDefinition) (interface{}, error) {
+	if def.Type != ParameterDateRange && def.Type != ParameterDateTimeRange && def.Type != ParameterDateTimeRangeWithSeconds {
+		return nil, invalidParameterKind(def, "date range")
+	}
+
+	if v.Dynamic != "" {
+		if !dynamicDateRanges[v.Dynamic] {
+			return nil, fmt.Errorf("Invalid value for parameter %s: unknown dynamic date range %s", def.Name, v.Dynamic)
+		}
+		return v.Dynamic, nil
+	}
+
+	if v.End.Before(v.Start) {
+		return nil, fmt.Errorf("Invalid value for parameter %s: range ends before it starts", def.Name)
+	}
+
+	layout := dateLayouts[def.Type]
+	return map[string]string{
+		"start": v.Start.Format(layout),
+		"end":   v.End.Format(layout),
+	}, nil
+}
+
+func invalidParameterKind(def *QueryParameterDefinition, kind string) error {
+	return fmt.Errorf("Invalid value for parameter %s: a %s value was given for a %s parameter", def.Name, kind, def.Type)
+}
+
+// ValidateQueryParameters checks the given values against the definitions
+// of a query's parameters and returns them encoded as Redash expects.
+// Parameters without a value fall back to their default, and are an error
+// when they have none
+func ValidateQueryParameters(definitions []QueryParameterDefinition, parameters QueryParameters) (map[string]interface{}, error) {
+	encoded := map[string]interface{}{}
+
+	defined := map[string]bool{}
+	for i := range definitions {
+		def := &definitions[i]
+		defined[def.Name] = true
+
+		value, ok := parameters[def.Name]
+		if !ok || value == nil {
+			if def.Value == nil {
+				return nil, fmt.Errorf("Missing value for parameter %s", def.Name)
+			}
+			encoded[def.Name] = def.Value
+			continue
+		}
+
+		v, err := value.encode(def)
+		if err != nil {
+			return nil, err
+		}
+		encoded[def.Name] = v
+	}
+
+	unknown := []string{}
+	for name := range parameters {
+		if !defined[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("Unknown parameters: %s", strings.Join(unknown, ", "))
+	}
+
+	return encoded, nil
+}
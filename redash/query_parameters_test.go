@@ -0,0 +1,143 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exportParameters are the parameter definitions of the export query
+var exportParameters = []QueryParameterDefinition{
+	{Name: "country", Type: ParameterText},
+	{Name: "limit", Type: ParameterNumber, Value: float64(100)},
+	{Name: "channel", Type: ParameterEnum, EnumOptions: "web\nmobile\nemail"},
+	{Name: "product", Type: ParameterQuery, QueryID: 7, MultiValuesOptions: &QueryParameterMultiValue{Separator: ","}},
+	{Name: "day", Type: ParameterDateTime},
+	{Name: "period", Type: ParameterDateRange},
+}
+
+func TestQueryParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	query := Query{ID: 1, Options: map[string]interface{}{
+		"parameters": []interface{}{
+			map[string]interface{}{"name": "channel", "title": "Channel", "type": "enum", "enumOptions": "web\nmobile", "value": "web"},
+			map[string]interface{}{"name": "period", "type": "date-range", "value": "d_last_7_days"},
+		},
+	}}
+
+	definitions, err := query.Parameters()
+	assert.Nil(err)
+	assert.Equal([]QueryParameterDefinition{
+		{Name: "channel", Title: "Channel", Type: ParameterEnum, EnumOptions: "web\nmobile", Value: "web"},
+		{Name: "period", Type: ParameterDateRange, Value: "d_last_7_days"},
+	}, definitions)
+	assert.Equal([]string{"web", "mobile"}, definitions[0].Options())
+
+	definitions, err = (&Query{}).Parameters()
+	assert.Nil(err)
+	assert.Empty(definitions)
+}
+
+func TestValidateQueryParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	encoded, err := ValidateQueryParameters(exportParameters, QueryParameters{
+		"country": TextValue("FR"),
+		"channel": Enum("mobile"),
+		"product": QueryDropdown("12", "13"),
+		"day":     Date(time.Date(2022, 3, 14, 9, 30, 0, 0, time.UTC)),
+		"period":  DateRange(start, end),
+	})
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{
+		"country": "FR",
+		"limit":   float64(100),
+		"channel": "mobile",
+		"product": []string{"12", "13"},
+		"day":     "2022-03-14 09:30",
+		"period":  map[string]string{"start": "2022-03-01", "end": "2022-03-31"},
+	}, encoded)
+
+	encoded, err = ValidateQueryParameters(exportParameters, QueryParameters{
+		"country": TextValue("FR"),
+		"limit":   NumberValue(10),
+		"channel": Enum("web"),
+		"product": QueryDropdown("12"),
+		"day":     DynamicDate("d_yesterday"),
+		"period":  DynamicDateRange("d_last_7_days"),
+	})
+	assert.Nil(err)
+	assert.Equal(float64(10), encoded["limit"])
+	assert.Equal([]string{"12"}, encoded["product"])
+	assert.Equal("d_yesterday", encoded["day"])
+	assert.Equal("d_last_7_days", encoded["period"])
+}
+
+func TestValidateQueryParametersErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	valid := func() QueryParameters {
+		return QueryParameters{
+			"country": TextValue("FR"),
+			"channel": Enum("web"),
+			"product": QueryDropdown("12"),
+			"day":     DynamicDate("d_now"),
+			"period":  DynamicDateRange("d_this_month"),
+		}
+	}
+
+	for expected, change := range map[string]func(QueryParameters){
+		"Missing value for parameter country": func(p QueryParameters) { delete(p, "country") },
+		"Unknown parameters: region":          func(p QueryParameters) { p["region"] = TextValue("EU") },
+		"Invalid value for parameter country: a number value was given for a text parameter":    func(p QueryParameters) { p["country"] = NumberValue(1) },
+		"Invalid value for parameter channel: \"sms\" is not one of web, mobile, email":         func(p QueryParameters) { p["channel"] = Enum("sms") },
+		"Parameter channel does not accept multiple values":                                     func(p QueryParameters) { p["channel"] = Enum("web", "email") },
+		"Missing value for parameter product":                                                   func(p QueryParameters) { p["product"] = QueryDropdown() },
+		"Invalid value for parameter day: unknown dynamic date d_tomorrow":                      func(p QueryParameters) { p["day"] = DynamicDate("d_tomorrow") },
+		"Invalid value for parameter period: unknown dynamic date range d_last_3_days":          func(p QueryParameters) { p["period"] = DynamicDateRange("d_last_3_days") },
+		"Invalid value for parameter period: a date value was given for a date-range parameter": func(p QueryParameters) { p["period"] = DynamicDate("d_now") },
+		"Invalid value for parameter period: range ends before it starts": func(p QueryParameters) {
+			p["period"] = DateRange(time.Now(), time.Now().Add(-time.Hour))
+		},
+	} {
+		parameters := valid()
+		change(parameters)
+
+		_, err := ValidateQueryParameters(exportParameters, parameters)
+		assert.EqualError(err, expected)
+	}
+}
+
+func TestValidateQueryParametersDynamicDateRanges(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, dynamic := range []string{"d_today", "d_yesterday", "d_last_hour", "d_last_8_hours", "d_last_24_hours"} {
+		encoded, err := ValidateQueryParameters(exportParameters, QueryParameters{
+			"country": TextValue("FR"),
+			"channel": Enum("web"),
+			"product": QueryDropdown("12"),
+			"day":     DynamicDate("d_now"),
+			"period":  DynamicDateRange(dynamic),
+		})
+		assert.Nil(err, dynamic)
+		assert.Equal(dynamic, encoded["period"])
+	}
+}
@@ -0,0 +1,96 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+)
+
+// ResultFormat is a file format Redash serves query results in
+type ResultFormat string
+
+// Formats query results can be downloaded in
+const (
+	FormatCSV  ResultFormat = "csv"
+	FormatTSV  ResultFormat = "tsv"
+	FormatXLSX ResultFormat = "xlsx"
+	FormatJSON ResultFormat = "json"
+)
+
+// resultContentTypes are the content types Redash serves each format with
+var resultContentTypes = map[ResultFormat]string{
+	FormatCSV:  "text/csv",
+	FormatTSV:  "text/tab-separated-values",
+	FormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatJSON: "application/json",
+}
+
+// ContentType returns the content type of the format
+func (f ResultFormat) ContentType() string {
+	return resultContentTypes[f]
+}
+
+// DownloadQueryResult writes a specific result of a Query in the given
+// format to w, as it is received, and returns the number of bytes written.
+// The download fails before anything is written when Redash answers with
+// another content type than the format's, e.g. an HTML error page
+func (c *Client) DownloadQueryResult(queryID QueryID, resultID QueryResultID, format ResultFormat, w io.Writer) (int64, error) {
+	return c.DownloadQueryResultContext(context.Background(), queryID, resultID, format, w)
+}
+
+// DownloadQueryResultContext is DownloadQueryResult bound to the given
+// context, which bounds the whole download
+func (c *Client) DownloadQueryResultContext(ctx context.Context, queryID QueryID, resultID QueryResultID, format ResultFormat, w io.Writer) (int64, error) {
+	path := "/api/queries/" + queryID.String() + "/results/" + resultID.String() + "." + string(format)
+	return c.downloadResult(ctx, path, format, w)
+}
+
+// DownloadLatestQueryResult writes the latest result of a Query in the
+// given format to w, as DownloadQueryResult does
+func (c *Client) DownloadLatestQueryResult(queryID QueryID, format ResultFormat, w io.Writer) (int64, error) {
+	return c.DownloadLatestQueryResultContext(context.Background(), queryID, format, w)
+}
+
+// DownloadLatestQueryResultContext is DownloadLatestQueryResult bound to the
+// given context, which bounds the whole download
+func (c *Client) DownloadLatestQueryResultContext(ctx context.Context, queryID QueryID, format ResultFormat, w io.Writer) (int64, error) {
+	path := "/api/queries/" + queryID.String() + "/results." + string(format)
+	return c.downloadResult(ctx, path, format, w)
+}
+
+func (c *Client) downloadResult(ctx context.Context, path string, format ResultFormat, w io.Writer) (int64, error) {
+	expected, ok := resultContentTypes[format]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported result format: %s", format)
+	}
+
+	query := url.Values{}
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	contentType := response.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != expected {
+		return 0, fmt.Errorf("Unexpected content type for %s result: %q", format, contentType)
+	}
+
+	return io.Copy(w, response.Body)
+}
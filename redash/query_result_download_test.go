@@ -0,0 +1,95 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fileResponder answers with the given body and content type
+func fileResponder(contentType, body string) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		response := httpmock.NewStringResponse(200, body)
+		response.Header.Set("Content-Type", contentType)
+		return response, nil
+	}
+}
+
+func TestDownloadQueryResult(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3/results/9.csv",
+		fileResponder("text/csv; charset=UTF-8", "day,events\r\n2022-03-01,12\r\n"))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3/results.tsv",
+		fileResponder("text/tab-separated-values", "day\tevents\n2022-03-01\t12\n"))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3/results.xlsx",
+		fileResponder("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "PK\x03\x04"))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3/results/9.json",
+		fileResponder("application/json", `{"query_result": {"id": 9}}`))
+
+	var buf bytes.Buffer
+	written, err := c.DownloadQueryResult(3, 9, FormatCSV, &buf)
+	assert.Nil(err)
+	assert.Equal(int64(27), written)
+	assert.Equal("day,events\r\n2022-03-01,12\r\n", buf.String())
+
+	buf.Reset()
+	_, err = c.DownloadLatestQueryResult(3, FormatTSV, &buf)
+	assert.Nil(err)
+	assert.Equal("day\tevents\n2022-03-01\t12\n", buf.String())
+
+	buf.Reset()
+	_, err = c.DownloadLatestQueryResult(3, FormatXLSX, &buf)
+	assert.Nil(err)
+	assert.Equal("PK\x03\x04", buf.String())
+
+	buf.Reset()
+	_, err = c.DownloadQueryResult(3, 9, FormatJSON, &buf)
+	assert.Nil(err)
+	assert.JSONEq(`{"query_result": {"id": 9}}`, buf.String())
+}
+
+func TestDownloadQueryResultErrors(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3/results/9.csv",
+		fileResponder("text/html; charset=utf-8", "<html>Login</html>"))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/4/results.csv",
+		httpmock.NewStringResponder(404, ""))
+
+	var buf bytes.Buffer
+	_, err := c.DownloadQueryResult(3, 9, FormatCSV, &buf)
+	assert.EqualError(err, `Unexpected content type for csv result: "text/html; charset=utf-8"`)
+	assert.Equal(0, buf.Len())
+
+	_, err = c.DownloadLatestQueryResult(4, FormatCSV, &buf)
+	assert.EqualError(err, "HTTP Response: 404")
+
+	_, err = c.DownloadLatestQueryResult(3, ResultFormat("pdf"), &buf)
+	assert.EqualError(err, "Unsupported result format: pdf")
+	assert.Equal("text/csv", FormatCSV.ContentType())
+}
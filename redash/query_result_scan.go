@@ -0,0 +1,345 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rows are scanned into structs field by field. A field receives the column
+// named by its `redash:"column_name"` tag, or the column matching its name
+// case-insensitively when it has no tag; `redash:"-"` skips it. Columns
+// without a field and fields without a column are left alone, and null
+// values leave the field at its zero value or set pointers to nil.
+//
+// Values are converted according to the column's type: integer, float and
+// boolean columns fit numeric, boolean and string fields, while date and
+// datetime columns fit time.Time fields as well as string ones
+
+// Column types of query results
+const (
+	ColumnInteger  = "integer"
+	ColumnFloat    = "float"
+	ColumnBoolean  = "boolean"
+	ColumnString   = "string"
+	ColumnDate     = "date"
+	ColumnDateTime = "datetime"
+)
+
+// dateTimeLayouts are the formats Redash serializes dates and datetimes in,
+// depending on the data source
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// scanField is a struct field rows are scanned into
+type scanField struct {
+	index []int
+	name  string
+}
+
+// scanFields maps the columns of the result to the fields of the struct type
+func (r *QueryResult) scanFields(structType reflect.Type) map[string]scanField {
+	fields := map[string]scanField{}
+
+	for _, field := range reflect.VisibleFields(structType) {
+		if !field.IsExported() || (field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		tag := field.Tag.Get("redash")
+		if tag == "-" {
+			continue
+		}
+
+		for _, column := range r.Data.Columns {
+			if (tag != "" && column.Name == tag) || (tag == "" && strings.EqualFold(column.Name, field.Name)) {
+				fields[column.Name] = scanField{index: field.Index, name: field.Name}
+			}
+		}
+	}
+
+	return fields
+}
+
+// ScanRows decodes every row of the result into dest, which must point to a
+// slice of structs or of pointers to structs
+func (r *QueryResult) ScanRows(dest interface{}) error {
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Cannot scan rows into %T: a pointer to a slice is required", dest)
+	}
+	slice = slice.Elem()
+
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot scan rows into %T: slice elements must be structs", dest)
+	}
+
+	fields := r.scanFields(structType)
+	rows := reflect.MakeSlice(slice.Type(), len(r.Data.Rows), len(r.Data.Rows))
+	for i := range r.Data.Rows {
+		row := reflect.New(structType)
+		if err := r.scanRow(i, row.Elem(), fields); err != nil {
+			return err
+		}
+
+		if elemType.Kind() == reflect.Ptr {
+			rows.Index(i).Set(row)
+		} else {
+			rows.Index(i).Set(row.Elem())
+		}
+	}
+
+	slice.Set(rows)
+	return nil
+}
+
+// ScanRow decodes the row at the given index into dest, which must point to
+// a struct
+func (r *QueryResult) ScanRow(index int, dest interface{}) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot scan a row into %T: a pointer to a struct is required", dest)
+	}
+
+	if index < 0 || index >= len(r.Data.Rows) {
+		return fmt.Errorf("Row %d out of range: the result has %d rows", index, len(r.Data.Rows))
+	}
+
+	return r.scanRow(index, value.Elem(), r.scanFields(value.Elem().Type()))
+}
+
+func (r *QueryResult) scanRow(index int, dest reflect.Value, fields map[string]scanField) error {
+	row := r.Data.Rows[index]
+
+	for _, column := range r.Data.Columns {
+		field, ok := fields[column.Name]
+		if !ok {
+			continue
+		}
+
+		err := scanValue(row[column.Name], column.Type, dest.FieldByIndex(field.index))
+		if err != nil {
+			return fmt.Errorf("Cannot scan column %s of row %d into field %s: %v", column.Name, index, field.name, err)
+		}
+	}
+
+	return nil
+}
+
+// scanValue converts a value of the given column type into dest
+func scanValue(value interface{}, columnType string, dest reflect.Value) error {
+	if value == nil {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+
+	if dest.Kind() == reflect.Ptr {
+		elem := reflect.New(dest.Type().Elem())
+		if err := scanValue(value, columnType, elem.Elem()); err != nil {
+			return err
+		}
+		dest.Set(elem)
+		return nil
+	}
+
+	if dest.Type() == timeType {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a date", value)
+		}
+		t, err := ParseDateTime(s)
+		if err != nil {
+			return err
+		}
+		dest.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch dest.Kind() {
+	case reflect.Interface:
+		switch v := value.(type) {
+		case string:
+			if columnType == ColumnDate || columnType == ColumnDateTime {
+				if t, err := ParseDateTime(v); err == nil {
+					value = t
+				}
+			}
+		case json.Number:
+			if i, err := v.Int64(); err == nil && columnType == ColumnInteger {
+				value = i
+			} else if f, err := v.Float64(); err == nil {
+				value = f
+			}
+		}
+		dest.Set(reflect.ValueOf(value))
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			dest.SetString(v)
+		case json.Number:
+			dest.SetString(v.String())
+		case float64:
+			dest.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			dest.SetString(strconv.FormatBool(v))
+		default:
+			return fmt.Errorf("Unsupported value %v", value)
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			dest.SetBool(v)
+		case json.Number, float64:
+			f, err := scanNumber(v)
+			if err != nil {
+				return err
+			}
+			dest.SetBool(f != 0)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			dest.SetBool(b)
+		default:
+			return fmt.Errorf("Unsupported value %v", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(numberText(value), 10, 64); err == nil {
+			if dest.OverflowInt(i) {
+				return fmt.Errorf("%v does not fit %s", value, dest.Type())
+			}
+			dest.SetInt(i)
+			return nil
+		}
+		f, err := scanNumber(value)
+		if err != nil {
+			return err
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || dest.OverflowInt(int64(f)) {
+			return fmt.Errorf("%v does not fit %s", value, dest.Type())
+		}
+		dest.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u, err := strconv.ParseUint(numberText(value), 10, 64); err == nil {
+			if dest.OverflowUint(u) {
+				return fmt.Errorf("%v does not fit %s", value, dest.Type())
+			}
+			dest.SetUint(u)
+			return nil
+		}
+		f, err := scanNumber(value)
+		if err != nil {
+			return err
+		}
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || dest.OverflowUint(uint64(f)) {
+			return fmt.Errorf("%v does not fit %s", value, dest.Type())
+		}
+		dest.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		f, err := scanNumber(value)
+		if err != nil {
+			return err
+		}
+		dest.SetFloat(f)
+	default:
+		return fmt.Errorf("Unsupported field type %s", dest.Type())
+	}
+
+	return nil
+}
+
+// numberText returns the text of a number kept as json.Number or a string,
+// which integers are parsed from so they keep every digit
+func numberText(value interface{}) string {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case string:
+		return v
+	}
+
+	return ""
+}
+
+// scanNumber returns a numeric value, parsing it when given as a string
+func scanNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("%v is not a number", value)
+}
+
+// ParseDateTime parses a date or datetime in any of the formats Redash
+// serializes them in, depending on the data source
+func ParseDateTime(value string) (time.Time, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%q is not a date", value)
+}
+
+// RunQueryInto runs a saved Query as RunQuery does and decodes its rows into
+// values of type T, which must be a struct or a pointer to a struct
+func RunQueryInto[T any](c *Client, id QueryID, parameters QueryParameters) ([]T, error) {
+	return RunQueryIntoContext[T](context.Background(), c, id, parameters)
+}
+
+// RunQueryIntoContext is RunQueryInto bound to the given context
+func RunQueryIntoContext[T any](ctx context.Context, c *Client, id QueryID, parameters QueryParameters) ([]T, error) {
+	result, err := c.RunQueryContext(ctx, id, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []T{}
+	if err := result.ScanRows(&rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
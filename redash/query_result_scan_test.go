@@ -0,0 +1,200 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// ordersResult holds a row of each column type, and a row of nulls
+const ordersResult = `{
+	"id": 4,
+	"data": {
+		"columns": [
+			{"name": "order_id", "type": "integer"},
+			{"name": "amount", "type": "float"},
+			{"name": "paid", "type": "boolean"},
+			{"name": "customer", "type": "string"},
+			{"name": "day", "type": "date"},
+			{"name": "created_at", "type": "datetime"},
+			{"name": "notes", "type": "string"}
+		],
+		"rows": [
+			{"order_id": 1001, "amount": 12.5, "paid": true, "customer": "Jane", "day": "2022-03-01", "created_at": "2022-03-01T10:15:00", "notes": "gift"},
+			{"order_id": 1002, "amount": null, "paid": false, "customer": "John", "day": "2022-03-02", "created_at": "2022-03-02T08:00:00+00:00", "notes": null}
+		]
+	}
+}`
+
+type audit struct {
+	CreatedAt time.Time `redash:"created_at"`
+}
+
+type order struct {
+	audit
+	ID       int64    `redash:"order_id"`
+	Amount   *float64 `redash:"amount"`
+	Paid     bool     `redash:"paid"`
+	Customer string
+	Day      time.Time `redash:"day"`
+	Notes    string    `redash:"-"`
+	Missing  string    `redash:"missing"`
+}
+
+func loadResult(t *testing.T, body string) *QueryResult {
+	result := QueryResult{}
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatal(err)
+	}
+	return &result
+}
+
+func TestScanRows(t *testing.T) {
+	assert := assert.New(t)
+
+	result := loadResult(t, ordersResult)
+
+	orders := []order{}
+	err := result.ScanRows(&orders)
+	assert.Nil(err)
+	assert.Len(orders, 2)
+
+	amount := 12.5
+	assert.Equal(order{
+		audit:    audit{CreatedAt: time.Date(2022, 3, 1, 10, 15, 0, 0, time.UTC)},
+		ID:       1001,
+		Amount:   &amount,
+		Paid:     true,
+		Customer: "Jane",
+		Day:      time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+	}, orders[0])
+	assert.Nil(orders[1].Amount)
+	assert.Equal(time.Date(2022, 3, 2, 8, 0, 0, 0, time.UTC), orders[1].CreatedAt.UTC())
+
+	pointers := []*order{}
+	err = result.ScanRows(&pointers)
+	assert.Nil(err)
+	assert.Equal(int64(1002), pointers[1].ID)
+
+	var row struct {
+		ID     string      `redash:"order_id"`
+		Amount int         `redash:"amount"`
+		Paid   string      `redash:"paid"`
+		Day    string      `redash:"day"`
+		Any    interface{} `redash:"created_at"`
+	}
+	err = result.ScanRow(1, &row)
+	assert.Nil(err)
+	assert.Equal("1002", row.ID)
+	assert.Equal(0, row.Amount)
+	assert.Equal("false", row.Paid)
+	assert.Equal("2022-03-02", row.Day)
+	assert.IsType(time.Time{}, row.Any)
+}
+
+func TestScanLargeIntegers(t *testing.T) {
+	assert := assert.New(t)
+
+	result := loadResult(t, `{"id": 5, "data": {
+		"columns": [{"name": "id", "type": "integer"}, {"name": "total", "type": "float"}],
+		"rows": [{"id": 9007199254740993, "total": 0.1}]
+	}}`)
+
+	var row struct {
+		ID    int64   `redash:"id"`
+		Total float64 `redash:"total"`
+	}
+	assert.Nil(result.ScanRow(0, &row))
+	assert.Equal(int64(9007199254740993), row.ID)
+	assert.Equal(0.1, row.Total)
+
+	var unsigned struct {
+		ID uint64 `redash:"id"`
+	}
+	assert.Nil(result.ScanRow(0, &unsigned))
+	assert.Equal(uint64(9007199254740993), unsigned.ID)
+
+	var text struct {
+		ID  string      `redash:"id"`
+		Any interface{} `redash:"total"`
+	}
+	assert.Nil(result.ScanRow(0, &text))
+	assert.Equal("9007199254740993", text.ID)
+	assert.Equal(0.1, text.Any)
+
+	var dynamic struct {
+		ID interface{} `redash:"id"`
+	}
+	assert.Nil(result.ScanRow(0, &dynamic))
+	assert.Equal(int64(9007199254740993), dynamic.ID)
+}
+
+func TestScanRowsErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	result := loadResult(t, ordersResult)
+
+	err := result.ScanRows([]order{})
+	assert.EqualError(err, "Cannot scan rows into []redash.order: a pointer to a slice is required")
+
+	err = result.ScanRows(&[]string{})
+	assert.EqualError(err, "Cannot scan rows into *[]string: slice elements must be structs")
+
+	err = result.ScanRow(2, &order{})
+	assert.EqualError(err, "Row 2 out of range: the result has 2 rows")
+
+	var fractional []struct {
+		Amount int `redash:"amount"`
+	}
+	err = result.ScanRows(&fractional)
+	assert.EqualError(err, "Cannot scan column amount of row 0 into field Amount: 12.5 does not fit int")
+
+	var small []struct {
+		ID int8 `redash:"order_id"`
+	}
+	err = result.ScanRows(&small)
+	assert.EqualError(err, "Cannot scan column order_id of row 0 into field ID: 1001 does not fit int8")
+
+	var dates []struct {
+		Customer time.Time
+	}
+	err = result.ScanRows(&dates)
+	assert.EqualError(err, `Cannot scan column customer of row 0 into field Customer: "Jane" is not a date`)
+}
+
+func TestRunQueryInto(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/4",
+		httpmock.NewStringResponder(200, `{"id": 4, "name": "Orders"}`))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/4/results",
+		httpmock.NewStringResponder(200, `{"query_result": `+ordersResult+`}`))
+
+	orders, err := RunQueryInto[order](c, 4, nil)
+	assert.Nil(err)
+	assert.Len(orders, 2)
+	assert.Equal("John", orders[1].Customer)
+
+	_, err = RunQueryInto[string](c, 4, nil)
+	assert.EqualError(err, "Cannot scan rows into *[]string: slice elements must be structs")
+}
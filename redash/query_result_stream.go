@@ -0,0 +1,339 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+)
+
+// QueryResultReader decodes the rows of a query result one at a time as
+// they are read from the response, so memory use does not grow with the
+// size of the result. It is used like sql.Rows:
+//
+//	reader, err := c.StreamQueryResult(id)
+//	...
+//	defer reader.Close()
+//	for reader.Next() {
+//		row := reader.Row()
+//		...
+//	}
+//	err = reader.Err()
+type QueryResultReader struct {
+	body    io.ReadCloser
+	decoder *json.Decoder
+
+	// depth is the object the decoder is in: 1 for the response, 2 for its
+	// query_result and 3 for the result's data
+	depth     int
+	inRows    bool
+	hasResult bool
+	job       *Job
+
+	columns []QueryResultColumn
+	row     map[string]interface{}
+	index   int
+	err     error
+
+	scan       QueryResult
+	scanFields map[reflect.Type]map[string]scanField
+}
+
+func newQueryResultReader(body io.ReadCloser) (*QueryResultReader, error) {
+	r := &QueryResultReader{
+		body:       body,
+		decoder:    json.NewDecoder(body),
+		index:      -1,
+		scanFields: map[reflect.Type]map[string]scanField{},
+	}
+	r.decoder.UseNumber()
+
+	if err := r.walk(); err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// walk decodes the response up to the start of the rows, or up to its end
+// once the rows have been read, keeping the columns and job found on the way
+func (r *QueryResultReader) walk() error {
+	for {
+		if r.depth == 0 {
+			if err := r.expect(json.Delim('{')); err != nil {
+				return err
+			}
+			r.depth = 1
+			continue
+		}
+
+		if !r.decoder.More() {
+			if err := r.expect(json.Delim('}')); err != nil {
+				return err
+			}
+			r.depth--
+			if r.depth == 0 {
+				return nil
+			}
+			continue
+		}
+
+		token, err := r.decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		switch {
+		case r.depth == 1 && key == "query_result":
+			err = r.expect(json.Delim('{'))
+			r.depth = 2
+			r.hasResult = true
+		case r.depth == 1 && key == "job":
+			r.job = &Job{}
+			err = r.decoder.Decode(r.job)
+		case r.depth == 2 && key == "data":
+			err = r.expect(json.Delim('{'))
+			r.depth = 3
+		case r.depth == 3 && key == "columns":
+			err = r.decoder.Decode(&r.columns)
+		case r.depth == 3 && key == "rows":
+			if err := r.expect(json.Delim('[')); err != nil {
+				return err
+			}
+			r.inRows = true
+			return nil
+		default:
+			var skipped json.RawMessage
+			err = r.decoder.Decode(&skipped)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *QueryResultReader) expect(delim json.Delim) error {
+	token, err := r.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("Invalid query result: expected %v, got %v", delim, token)
+	}
+
+	return nil
+}
+
+// Columns returns the columns of the result. They are usually known from
+// the start, but only once all rows are read when Redash sends them last
+func (r *QueryResultReader) Columns() []QueryResultColumn {
+	return r.columns
+}
+
+// Next decodes the next row, returning false once there are no more rows
+// or an error occurred, which Err then reports
+func (r *QueryResultReader) Next() bool {
+	r.row = nil
+	if !r.inRows || r.err != nil {
+		return false
+	}
+
+	if r.decoder.More() {
+		row := map[string]interface{}{}
+		if err := r.decoder.Decode(&row); err != nil {
+			r.err = err
+			return false
+		}
+		r.row = row
+		r.index++
+		return true
+	}
+
+	r.inRows = false
+	if err := r.expect(json.Delim(']')); err != nil {
+		r.err = err
+		return false
+	}
+	r.err = r.walk()
+
+	return false
+}
+
+// Row returns the row decoded by the last call to Next. Numbers are
+// json.Number values, as in QueryResultData
+func (r *QueryResultReader) Row() map[string]interface{} {
+	return r.row
+}
+
+// Scan decodes the row read by the last call to Next into dest, which must
+// point to a struct, as QueryResult.ScanRow does
+func (r *QueryResultReader) Scan(dest interface{}) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot scan a row into %T: a pointer to a struct is required", dest)
+	}
+	if r.row == nil {
+		return fmt.Errorf("No row to scan: Next must be called first")
+	}
+
+	r.scan.Data.Columns = r.columns
+	if r.scan.Data.Columns == nil {
+		r.scan.Data.Columns = columnsOf(r.row)
+	}
+	r.scan.Data.Rows = []map[string]interface{}{r.row}
+
+	structType := value.Elem().Type()
+	fields, ok := r.scanFields[structType]
+	if !ok || r.columns == nil {
+		fields = r.scan.scanFields(structType)
+		r.scanFields[structType] = fields
+	}
+
+	err := r.scan.scanRow(0, value.Elem(), fields)
+	if err != nil {
+		return fmt.Errorf("Row %d: %v", r.index, err)
+	}
+
+	return nil
+}
+
+// columnsOf lists the columns of a row, without their type
+func columnsOf(row map[string]interface{}) []QueryResultColumn {
+	names := make([]string, 0, len(row))
+	for name := range row {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	columns := make([]QueryResultColumn, len(names))
+	for i, name := range names {
+		columns[i] = QueryResultColumn{Name: name}
+	}
+
+	return columns
+}
+
+// Err returns the error which stopped Next, if any
+func (r *QueryResultReader) Err() error {
+	return r.err
+}
+
+// Close releases the response. It must be called even when all rows have
+// been read
+func (r *QueryResultReader) Close() error {
+	r.inRows = false
+	return r.body.Close()
+}
+
+// StreamQueryResult reads a specific QueryResult incrementally
+func (c *Client) StreamQueryResult(id QueryResultID) (*QueryResultReader, error) {
+	return c.StreamQueryResultContext(context.Background(), id)
+}
+
+// StreamQueryResultContext is StreamQueryResult bound to the given context,
+// which bounds the whole read, not only the request
+func (c *Client) StreamQueryResultContext(ctx context.Context, id QueryResultID) (*QueryResultReader, error) {
+	path := "/api/query_results/" + id.String()
+
+	query := url.Values{}
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return newQueryResultReader(response.Body)
+}
+
+// StreamQuery runs a saved Query as RunQuery does, and reads its result
+// incrementally as StreamQueryResult does
+func (c *Client) StreamQuery(id QueryID, parameters QueryParameters) (*QueryResultReader, error) {
+	return c.StreamQueryContext(context.Background(), id, parameters)
+}
+
+// StreamQueryContext is StreamQuery bound to the given context, which bounds
+// the run and the whole read
+func (c *Client) StreamQueryContext(ctx context.Context, id QueryID, parameters QueryParameters) (*QueryResultReader, error) {
+	executionPayload, err := c.queryExecution(ctx, id, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/api/queries/" + id.String() + "/results"
+	return c.streamExecution(ctx, path, executionPayload, fmt.Sprintf("query %d", id))
+}
+
+// StreamAdhocQuery runs a query text as RunAdhocQuery does, and reads its
+// result incrementally as StreamQueryResult does
+func (c *Client) StreamAdhocQuery(dataSourceID DataSourceID, queryText string, parameters map[string]interface{}) (*QueryResultReader, error) {
+	return c.StreamAdhocQueryContext(context.Background(), dataSourceID, queryText, parameters)
+}
+
+// StreamAdhocQueryContext is StreamAdhocQuery bound to the given context,
+// which bounds the run and the whole read
+func (c *Client) StreamAdhocQueryContext(ctx context.Context, dataSourceID DataSourceID, queryText string, parameters map[string]interface{}) (*QueryResultReader, error) {
+	executionPayload := &queryExecutionPayload{
+		DataSourceID: dataSourceID,
+		Query:        queryText,
+		Parameters:   parameters,
+	}
+
+	return c.streamExecution(ctx, "/api/query_results", executionPayload, "ad-hoc query")
+}
+
+// streamExecution asks Redash for a result as executeQuery does, reading it
+// incrementally
+func (c *Client) streamExecution(ctx context.Context, path string, executionPayload *queryExecutionPayload, name string) (*QueryResultReader, error) {
+	executionPayload.MaxAge = c.maxAge(ctx)
+	payload, err := json.Marshal(executionPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	response, err := c.postContext(ctx, path, string(payload), query)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := newQueryResultReader(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if reader.hasResult {
+		return reader, nil
+	}
+	reader.Close()
+
+	if reader.job == nil {
+		return nil, fmt.Errorf("Running %s returned neither a result nor a job", name)
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, c.jobTimeout())
+	defer cancel()
+
+	job, err := c.waitForJob(jobCtx, reader.job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Error running %s: %w", name, err)
+	}
+
+	return c.StreamQueryResultContext(ctx, job.QueryResultID)
+}
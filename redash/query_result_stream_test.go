@@ -0,0 +1,209 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamedRows answers with a result of the given number of rows, written
+// as they are read so the body is never held in memory
+func streamedRows(count int) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		reader, writer := io.Pipe()
+		go func() {
+			fmt.Fprint(writer, `{"query_result": {"id": 9, "data": {"columns": [{"name": "n", "type": "integer"}, {"name": "label", "type": "string"}], "rows": [`)
+			for i := 0; i < count; i++ {
+				if i > 0 {
+					fmt.Fprint(writer, ",")
+				}
+				fmt.Fprintf(writer, `{"n": %d, "label": "row %d"}`, i, i)
+			}
+			fmt.Fprint(writer, `]}, "retrieved_at": "2022-03-14T09:30:00Z"}}`)
+			writer.Close()
+		}()
+
+		return &http.Response{StatusCode: 200, Body: reader, Header: http.Header{}}, nil
+	}
+}
+
+func TestStreamQueryResult(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/query_results/9", streamedRows(20000))
+
+	reader, err := c.StreamQueryResult(9)
+	assert.Nil(err)
+	defer reader.Close()
+
+	assert.Equal([]QueryResultColumn{{Name: "n", Type: "integer"}, {Name: "label", Type: "string"}}, reader.Columns())
+
+	var row struct {
+		N     int    `redash:"n"`
+		Label string `redash:"label"`
+	}
+	count := 0
+	for reader.Next() {
+		assert.Nil(reader.Scan(&row))
+		if row.N != count || row.Label != fmt.Sprintf("row %d", count) {
+			t.Fatalf("Unexpected row %d: %+v", count, row)
+		}
+		count++
+	}
+	assert.Nil(reader.Err())
+	assert.Equal(20000, count)
+	assert.False(reader.Next())
+}
+
+func TestStreamQueryResultColumnsLast(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/query_results/9",
+		httpmock.NewStringResponder(200, `{"query_result": {"data": {"rows": [{"day": "2022-03-01", "events": 3}], "columns": [{"name": "day", "type": "date"}, {"name": "events", "type": "integer"}]}, "id": 9}}`))
+
+	reader, err := c.StreamQueryResult(9)
+	assert.Nil(err)
+	defer reader.Close()
+
+	assert.Nil(reader.Columns())
+	assert.True(reader.Next())
+	assert.Equal(map[string]interface{}{"day": "2022-03-01", "events": json.Number("3")}, reader.Row())
+
+	var row struct {
+		Day    time.Time `redash:"day"`
+		Events int64     `redash:"events"`
+	}
+	assert.Nil(reader.Scan(&row))
+	assert.Equal(int64(3), row.Events)
+	assert.Equal(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), row.Day)
+
+	assert.False(reader.Next())
+	assert.Nil(reader.Err())
+	assert.Len(reader.Columns(), 2)
+	assert.EqualError(reader.Scan(&row), "No row to scan: Next must be called first")
+}
+
+func TestStreamQueryResultLargeIntegers(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/query_results/9",
+		httpmock.NewStringResponder(200, `{"query_result": {"data": {"columns": [{"name": "id", "type": "integer"}], "rows": [{"id": 9007199254740993}]}}}`))
+
+	reader, err := c.StreamQueryResult(9)
+	assert.Nil(err)
+	defer reader.Close()
+
+	var row struct {
+		ID int64 `redash:"id"`
+	}
+	assert.True(reader.Next())
+	assert.Nil(reader.Scan(&row))
+	assert.Equal(int64(9007199254740993), row.ID)
+}
+
+func TestStreamQueryResultErrors(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/query_results/9",
+		httpmock.NewStringResponder(200, `{"query_result": {"data": {"rows": [{"n": 1}, {"n": "two"}, {"n": 3}, `))
+
+	reader, err := c.StreamQueryResult(9)
+	assert.Nil(err)
+	defer reader.Close()
+
+	var row struct {
+		N int `redash:"n"`
+	}
+	assert.True(reader.Next())
+	assert.Nil(reader.Scan(&row))
+	assert.True(reader.Next())
+	assert.EqualError(reader.Scan(&row), `Row 1: Cannot scan column n of row 0 into field N: strconv.ParseFloat: parsing "two": invalid syntax`)
+	assert.True(reader.Next())
+	assert.False(reader.Next())
+	assert.NotNil(reader.Err())
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/query_results/10",
+		httpmock.NewStringResponder(200, `[]`))
+	_, err = c.StreamQueryResult(10)
+	assert.EqualError(err, "Invalid query result: expected {, got [")
+}
+
+func TestStreamQuery(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 1}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 3, "query_result_id": 9}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/query_results/9", streamedRows(3))
+
+	reader, err := c.StreamQuery(3, QueryParameters{"channel": Enum("mobile")})
+	assert.Nil(err)
+	defer reader.Close()
+
+	count := 0
+	for reader.Next() {
+		count++
+	}
+	assert.Nil(reader.Err())
+	assert.Equal(3, count)
+
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		httpmock.NewStringResponder(200, exportResult))
+
+	reader, err = c.StreamQuery(3, nil)
+	assert.Nil(err)
+	defer reader.Close()
+
+	assert.True(reader.Next())
+	assert.Equal("2022-03-01", reader.Row()["day"])
+
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		httpmock.NewStringResponder(200, `{"message": "ok"}`))
+
+	_, err = c.StreamQuery(3, nil)
+	assert.EqualError(err, "Running query 3 returned neither a result nor a job")
+	_, err = c.RunQuery(3, nil)
+	assert.EqualError(err, "Running query 3 returned neither a result nor a job")
+}
@@ -0,0 +1,257 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+)
+
+// QueryResult struct
+type QueryResult struct {
+	ID           QueryResultID   `json:"id"`
+	QueryHash    string          `json:"query_hash,omitempty"`
+	Query        string          `json:"query,omitempty"`
+	DataSourceID DataSourceID    `json:"data_source_id,omitempty"`
+	Runtime      float64         `json:"runtime,omitempty"`
+	RetrievedAt  *time.Time      `json:"retrieved_at,omitempty"`
+	Data         QueryResultData `json:"data"`
+}
+
+// QueryResultData struct. Numbers in Rows are json.Number values
+type QueryResultData struct {
+	Columns []QueryResultColumn      `json:"columns"`
+	Rows    []map[string]interface{} `json:"rows"`
+}
+
+// UnmarshalJSON decodes the numbers of rows as json.Number, so integers
+// beyond 2^53 keep every digit
+func (d *QueryResultData) UnmarshalJSON(data []byte) error {
+	type queryResultData QueryResultData
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode((*queryResultData)(d))
+}
+
+// QueryResultColumn struct. Type is one of Redash's column types: integer,
+// float, boolean, string, date or datetime
+type QueryResultColumn struct {
+	Name         string `json:"name"`
+	FriendlyName string `json:"friendly_name,omitempty"`
+	Type         string `json:"type,omitempty"`
+}
+
+// queryResultResponse covers both a cached result and the job running the
+// query when no suitable result is cached
+type queryResultResponse struct {
+	QueryResult *QueryResult `json:"query_result"`
+	Job         *Job         `json:"job"`
+}
+
+// queryExecutionPayload struct. MaxAge is the age in seconds of the oldest
+// cached result to accept, zero forcing the query to run. DataSourceID and
+// Query are only set for ad-hoc queries, and version, which is not sent,
+// only for saved ones
+type queryExecutionPayload struct {
+	DataSourceID DataSourceID           `json:"data_source_id,omitempty"`
+	Query        string                 `json:"query,omitempty"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	MaxAge       int                    `json:"max_age"`
+
+	version int
+}
+
+// GetQueryResult gets a specific QueryResult
+func (c *Client) GetQueryResult(id QueryResultID) (*QueryResult, error) {
+	return c.GetQueryResultContext(context.Background(), id)
+}
+
+// GetQueryResultContext is GetQueryResult bound to the given context
+func (c *Client) GetQueryResultContext(ctx context.Context, id QueryResultID) (*QueryResult, error) {
+	path := "/api/query_results/" + id.String()
+
+	query := url.Values{}
+	response, err := c.getContext(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resultResponse := queryResultResponse{}
+	err = json.Unmarshal(body, &resultResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if resultResponse.QueryResult == nil {
+		return nil, fmt.Errorf("Query result %d is missing from the response", id)
+	}
+
+	return resultResponse.QueryResult, nil
+}
+
+// RunQuery executes a saved Query with the given parameter values and waits
+// for its result. The values are validated against the query's parameter
+// definitions first, parameters left out taking their default value. With a
+// ResultCache configured, a result cached for the same version of the query
+// and values, and not older than the max age, is returned without running it
+func (c *Client) RunQuery(id QueryID, parameters QueryParameters) (*QueryResult, error) {
+	return c.RunQueryContext(context.Background(), id, parameters)
+}
+
+// RunQueryContext is RunQuery bound to the given context, which also bounds
+// the wait for the result
+func (c *Client) RunQueryContext(ctx context.Context, id QueryID, parameters QueryParameters) (*QueryResult, error) {
+	return c.runQuery(ctx, id, parameters, c.waitForJob)
+}
+
+// jobWaiter waits for a job to succeed, as waitForJob does
+type jobWaiter func(ctx context.Context, id JobID) (*Job, error)
+
+// runQuery runs a saved Query as RunQueryContext does, waiting for its job
+// with wait
+func (c *Client) runQuery(ctx context.Context, id QueryID, parameters QueryParameters, wait jobWaiter) (*QueryResult, error) {
+	executionPayload, err := c.queryExecution(ctx, id, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	var key ResultCacheKey
+	if c.Config.ResultCache != nil {
+		key, err = newResultCacheKey(id, executionPayload.version, executionPayload.Parameters)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if maxAge, bypass := c.cacheMaxAge(ctx); !bypass {
+		if result, ok := c.cachedResult(key, maxAge); ok {
+			return result, nil
+		}
+	}
+
+	path := "/api/queries/" + id.String() + "/results"
+	result, err := c.executeQuery(ctx, path, executionPayload, fmt.Sprintf("query %d", id), wait)
+	if err != nil {
+		return nil, err
+	}
+	c.cacheResult(key, result)
+
+	return result, nil
+}
+
+// RunAdhocQuery runs a query text against a DataSource and waits for its
+// result. Parameters fill the text's {{ parameter }} placeholders
+func (c *Client) RunAdhocQuery(dataSourceID DataSourceID, queryText string, parameters map[string]interface{}) (*QueryResult, error) {
+	return c.RunAdhocQueryContext(context.Background(), dataSourceID, queryText, parameters)
+}
+
+// RunAdhocQueryContext is RunAdhocQuery bound to the given context, which
+// also bounds the wait for the result
+func (c *Client) RunAdhocQueryContext(ctx context.Context, dataSourceID DataSourceID, queryText string, parameters map[string]interface{}) (*QueryResult, error) {
+	executionPayload := &queryExecutionPayload{
+		DataSourceID: dataSourceID,
+		Query:        queryText,
+		Parameters:   parameters,
+	}
+
+	return c.executeQuery(ctx, "/api/query_results", executionPayload, "ad-hoc query", c.waitForJob)
+}
+
+// queryExecution validates parameter values against the definitions of the
+// query and returns the payload executing it with them
+func (c *Client) queryExecution(ctx context.Context, id QueryID, parameters QueryParameters) (*queryExecutionPayload, error) {
+	redashQuery, err := c.GetQueryContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := redashQuery.Parameters()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := ValidateQueryParameters(definitions, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queryExecutionPayload{Parameters: encoded, version: redashQuery.Version}, nil
+}
+
+// executeQuery asks Redash for a result of the query and waits for the job
+// computing it, if any. name describes the query in errors
+func (c *Client) executeQuery(ctx context.Context, path string, executionPayload *queryExecutionPayload, name string, wait jobWaiter) (*QueryResult, error) {
+	result, job, err := c.submitQuery(ctx, path, executionPayload, name)
+	if err != nil || result != nil {
+		return result, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.jobTimeout())
+	defer cancel()
+
+	job, err = wait(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Error running %s: %w", name, err)
+	}
+
+	return c.GetQueryResultContext(ctx, job.QueryResultID)
+}
+
+// submitQuery asks Redash for a result of the query, returning either the
+// result, when a suitable one is cached, or the job computing it
+func (c *Client) submitQuery(ctx context.Context, path string, executionPayload *queryExecutionPayload, name string) (*QueryResult, *Job, error) {
+	executionPayload.MaxAge = c.maxAge(ctx)
+	payload, err := json.Marshal(executionPayload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := url.Values{}
+	response, err := c.postContext(ctx, path, string(payload), query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resultResponse := queryResultResponse{}
+	err = json.Unmarshal(body, &resultResponse)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resultResponse.QueryResult != nil {
+		return resultResponse.QueryResult, nil, nil
+	}
+
+	if resultResponse.Job == nil {
+		return nil, nil, fmt.Errorf("Running %s returned neither a result nor a job", name)
+	}
+
+	return nil, resultResponse.Job, nil
+}
@@ -0,0 +1,161 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// exportQuery is a saved query taking a date range and a channel
+const exportQuery = `{
+	"id": 3,
+	"name": "Export",
+	"query": "SELECT * FROM events WHERE channel = '{{ channel }}' AND day BETWEEN '{{ period.start }}' AND '{{ period.end }}'",
+	"data_source_id": 1,
+	"options": {
+		"parameters": [
+			{"name": "channel", "type": "enum", "enumOptions": "web\nmobile", "value": "web"},
+			{"name": "period", "type": "date-range", "value": "d_last_7_days"}
+		]
+	}
+}`
+
+// exportResult is a result of the export query
+const exportResult = `{"query_result": {
+	"id": 9,
+	"query_hash": "a1b2",
+	"data_source_id": 1,
+	"runtime": 0.5,
+	"retrieved_at": "2022-03-14T09:30:00Z",
+	"data": {
+		"columns": [
+			{"name": "day", "friendly_name": "Day", "type": "date"},
+			{"name": "events", "friendly_name": "Events", "type": "integer"}
+		],
+		"rows": [
+			{"day": "2022-03-01", "events": 12},
+			{"day": "2022-03-02", "events": 7}
+		]
+	}
+}}`
+
+// captureExecution answers query executions with the given body, recording
+// the payload they were sent with
+func captureExecution(payload *map[string]interface{}, body string) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		raw, _ := ioutil.ReadAll(req.Body)
+		*payload = map[string]interface{}{}
+		if err := json.Unmarshal(raw, payload); err != nil {
+			return nil, err
+		}
+		return httpmock.NewStringResponse(200, body), nil
+	}
+}
+
+func TestRunQuery(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	var sent map[string]interface{}
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		captureExecution(&sent, `{"job": {"id": "77ab", "status": 1}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/77ab",
+		sequenceResponder(
+			`{"job": {"id": "77ab", "status": 2}}`,
+			`{"job": {"id": "77ab", "status": 3, "query_result_id": 9}}`,
+		))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/query_results/9",
+		httpmock.NewStringResponder(200, exportResult))
+
+	result, err := c.RunQuery(3, QueryParameters{
+		"channel": Enum("mobile"),
+		"period": DateRange(
+			time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, 3, 7, 0, 0, 0, 0, time.UTC),
+		),
+	})
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{
+		"parameters": map[string]interface{}{
+			"channel": "mobile",
+			"period":  map[string]interface{}{"start": "2022-03-01", "end": "2022-03-07"},
+		},
+		"max_age": float64(0),
+	}, sent)
+
+	assert.Equal(QueryResultID(9), result.ID)
+	assert.Equal([]QueryResultColumn{
+		{Name: "day", FriendlyName: "Day", Type: "date"},
+		{Name: "events", FriendlyName: "Events", Type: "integer"},
+	}, result.Data.Columns)
+	assert.Len(result.Data.Rows, 2)
+	assert.Equal(json.Number("12"), result.Data.Rows[0]["events"])
+	assert.Equal(2, httpmock.GetCallCountInfo()["GET https://com.acme/api/jobs/77ab"])
+
+	_, err = c.RunQuery(3, nil)
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"channel": "web", "period": "d_last_7_days"}, sent["parameters"])
+}
+
+func TestRunQueryErrors(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy", JobPollInterval: time.Millisecond})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 1}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/77ab",
+		httpmock.NewStringResponder(200, `{"job": {"id": "77ab", "status": 4, "error": "relation \"events\" does not exist"}}`))
+
+	_, err := c.RunQuery(3, QueryParameters{"channel": Enum("sms")})
+	assert.EqualError(err, `Invalid value for parameter channel: "sms" is not one of web, mobile`)
+	assert.Equal(0, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+
+	_, err = c.RunQuery(3, nil)
+	assert.EqualError(err, `Error running query 3: Job 77ab failed: relation "events" does not exist`)
+}
+
+func TestRunQueryCachedResult(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		httpmock.NewStringResponder(200, exportResult))
+
+	result, err := c.RunQuery(3, nil)
+	assert.Nil(err)
+	assert.Equal(QueryResultID(9), result.ID)
+	assert.Equal(time.Date(2022, 3, 14, 9, 30, 0, 0, time.UTC), *result.RetrievedAt)
+}
@@ -0,0 +1,278 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Secrets are replaced by DataSourceSecretMask whenever a Config, a
+// DataSource or a request body is printed or logged. The secret options of
+// a data source are those its type's ConfigurationSchema.Secret lists, as
+// learnt by a Client or, for the types with typed options, as Redash
+// defines them. Options of other types count as secret when their name
+// looks like a credential
+
+// builtinDataSourceSecrets are the ConfigurationSchema.Secret lists Redash
+// defines for the types with typed options
+var builtinDataSourceSecrets = map[string][]string{
+	"pg":         {"password", "sslrootcertFile", "sslcertFile", "sslkeyFile"},
+	"redshift":   {"password"},
+	"mysql":      {"passwd"},
+	"bigquery":   {"jsonKeyFile"},
+	"snowflake":  {"password"},
+	"athena":     {"aws_secret_key"},
+	"clickhouse": {"password"},
+	"mssql":      {"password"},
+	"results":    {},
+}
+
+// secretOptionHints are the name fragments of options treated as secrets
+// when their type is unknown
+var secretOptionHints = []string{
+	"pass", "secret", "token", "credential",
+	"api_key", "apikey", "private_key", "privatekey", "access_key", "accesskey", "keyfile", "key_file",
+}
+
+// secretRegistry holds the secret options of each data source type a
+// Client has learnt. A nil registry only knows the built-in types
+type secretRegistry struct {
+	sync.RWMutex
+	byType map[string]map[string]bool
+}
+
+func newSecretRegistry() *secretRegistry {
+	return &secretRegistry{byType: map[string]map[string]bool{}}
+}
+
+func (r *secretRegistry) register(dataSourceTypes []DataSourceType) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, dst := range dataSourceTypes {
+		secrets := map[string]bool{}
+		for _, secret := range dst.ConfigurationSchema.Secret {
+			secrets[secret] = true
+		}
+		r.byType[dst.Type] = secrets
+	}
+}
+
+// secretsOf returns the secret options of the given type, and whether the
+// type is known at all
+func (r *secretRegistry) secretsOf(typeName string) (map[string]bool, bool) {
+	if r != nil {
+		r.RLock()
+		secrets, ok := r.byType[typeName]
+		r.RUnlock()
+		if ok {
+			return secrets, true
+		}
+	}
+
+	builtin, ok := builtinDataSourceSecrets[typeName]
+	if !ok {
+		return nil, false
+	}
+
+	secrets := make(map[string]bool, len(builtin))
+	for _, secret := range builtin {
+		secrets[secret] = true
+	}
+
+	return secrets, true
+}
+
+// isSecret returns true if the named option of the given type must not be
+// printed. Names are only guessed from when the type is unknown
+func (r *secretRegistry) isSecret(typeName, option string) bool {
+	if secrets, ok := r.secretsOf(typeName); ok {
+		return secrets[option]
+	}
+
+	return looksSecret(option)
+}
+
+// looksSecret returns true if the name looks like that of a credential
+func looksSecret(name string) bool {
+	lower := strings.ToLower(name)
+	for _, hint := range secretOptionHints {
+		if strings.Contains(lower, hint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RegisterDataSourceSecrets records the secret options of the given types
+// so the Client redacts them from its output. Types fetched by
+// GetDataSourceTypes or set as Config.DataSourceTypes are registered
+// automatically
+func (c *Client) RegisterDataSourceSecrets(dataSourceTypes []DataSourceType) {
+	c.secrets.register(dataSourceTypes)
+}
+
+// RedactDataSource returns a copy of the DataSource with the secret options
+// of its type masked, including those only the Client knows about
+func (c *Client) RedactDataSource(ds DataSource) DataSource {
+	ds.Options = c.secrets.redactOptions(ds.Type, ds.Options)
+	return ds
+}
+
+// redactSecret masks a secret unless it is empty
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return DataSourceSecretMask
+}
+
+// redactOptions returns a copy of the options of a data source of the given
+// type with every secret masked
+func (r *secretRegistry) redactOptions(typeName string, options map[string]interface{}) map[string]interface{} {
+	if options == nil {
+		return nil
+	}
+
+	redacted := make(map[string]interface{}, len(options))
+	for name, value := range options {
+		if r.isSecret(typeName, name) {
+			value = DataSourceSecretMask
+		}
+		redacted[name] = value
+	}
+
+	return redacted
+}
+
+// redactBody masks the secrets of a JSON request body before it is logged.
+// Options are redacted according to the body's type, and any other field
+// whose name looks like a credential is masked. Bodies which are not JSON
+// are replaced by their size, as their secrets cannot be located
+func (r *secretRegistry) redactBody(body string) string {
+	var payload interface{}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		return fmt.Sprintf("(%d bytes)", len(body))
+	}
+
+	redacted, err := json.Marshal(r.redactValue("", payload))
+	if err != nil {
+		return fmt.Sprintf("(%d bytes)", len(body))
+	}
+
+	return string(redacted)
+}
+
+func (r *secretRegistry) redactValue(typeName string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if t, ok := v["type"].(string); ok {
+			typeName = t
+		}
+
+		redacted := make(map[string]interface{}, len(v))
+		for name, field := range v {
+			switch {
+			case name == "options":
+				if options, ok := field.(map[string]interface{}); ok {
+					field = r.redactOptions(typeName, options)
+				}
+			case looksSecret(name):
+				field = DataSourceSecretMask
+			default:
+				field = r.redactValue(typeName, field)
+			}
+			redacted[name] = field
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = r.redactValue(typeName, item)
+		}
+		return redacted
+	}
+
+	return value
+}
+
+// plainConfig has the fields of Config but none of its methods
+type plainConfig Config
+
+func (config Config) redacted() plainConfig {
+	config.APIKey = redactSecret(config.APIKey)
+	return plainConfig(config)
+}
+
+// String formats the Config with its APIKey masked
+func (config Config) String() string {
+	return fmt.Sprintf("%+v", config.redacted())
+}
+
+// GoString formats the Config as %#v does, with its APIKey masked
+func (config Config) GoString() string {
+	return strings.Replace(fmt.Sprintf("%#v", config.redacted()), "redash.plainConfig", "redash.Config", 1)
+}
+
+// LogValue logs the Config with its APIKey masked
+func (config Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("redash_uri", config.RedashURI),
+		slog.String("api_key", redactSecret(config.APIKey)),
+		slog.Bool("strict_mode", config.StrictMode),
+		slog.Bool("preserve_secrets", config.PreserveSecrets),
+		slog.Bool("strict_decoding", config.StrictDecoding),
+		slog.Duration("job_poll_interval", config.JobPollInterval),
+		slog.Duration("job_timeout", config.JobTimeout),
+	)
+}
+
+// plainDataSource has the fields of DataSource but none of its methods
+type plainDataSource DataSource
+
+// redacted masks the secret options of built-in types, and the options
+// whose name looks like a credential for other types; only
+// Client.RedactDataSource knows the secrets of the types it learnt
+func (ds DataSource) redacted() plainDataSource {
+	var none *secretRegistry
+	ds.Options = none.redactOptions(ds.Type, ds.Options)
+	return plainDataSource(ds)
+}
+
+// String formats the DataSource with its secret options masked
+func (ds DataSource) String() string {
+	return fmt.Sprintf("%+v", ds.redacted())
+}
+
+// GoString formats the DataSource as %#v does, with its secret options
+// masked
+func (ds DataSource) GoString() string {
+	return strings.Replace(fmt.Sprintf("%#v", ds.redacted()), "redash.plainDataSource", "redash.DataSource", 1)
+}
+
+// LogValue logs the DataSource with its secret options masked
+func (ds DataSource) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("id", int(ds.ID)),
+		slog.String("name", ds.Name),
+		slog.String("type", ds.Type),
+		slog.Any("options", ds.redacted().Options),
+	)
+}
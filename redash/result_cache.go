@@ -0,0 +1,392 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ResultCacheKey identifies the result of a version of a saved Query run
+// with given parameter values. Parameters is a digest of the values,
+// defaults included
+type ResultCacheKey struct {
+	QueryID    QueryID
+	Version    int
+	Parameters string
+}
+
+// newResultCacheKey returns the key of the query version run with the
+// encoded parameter values
+func newResultCacheKey(id QueryID, version int, parameters map[string]interface{}) (ResultCacheKey, error) {
+	// maps are encoded with sorted keys, so equal values give equal digests
+	encoded, err := json.Marshal(parameters)
+	if err != nil {
+		return ResultCacheKey{}, err
+	}
+	digest := sha256.Sum256(encoded)
+
+	return ResultCacheKey{QueryID: id, Version: version, Parameters: hex.EncodeToString(digest[:])}, nil
+}
+
+// ResultCache keeps query results on the client side, so RunQuery does not
+// ask Redash again for a result it already got. Implementations must be
+// safe for concurrent use, treat expired entries as missing and never share
+// a result with their callers, which may modify it.
+//
+// An entry expires once it has been cached for longer than the cache's TTL,
+// whatever the run asks for, while the max age of a run only lets it use
+// results computed by Redash less than that long ago. Results older than
+// the max age are skipped but kept, for runs allowing older results
+type ResultCache interface {
+	// Get returns the result cached under the key, if any and not older
+	// than maxAge, zero leaving the age of the result unchecked
+	Get(key ResultCacheKey, maxAge time.Duration) (*QueryResult, bool)
+	// Set caches a result under the key
+	Set(key ResultCacheKey, result *QueryResult) error
+	// Invalidate drops the results of the query for all parameter values
+	Invalidate(id QueryID) error
+}
+
+// MemoryResultCache is a ResultCache keeping the most recently used results
+// in memory
+type MemoryResultCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[ResultCacheKey]*list.Element
+}
+
+type memoryResultEntry struct {
+	key      ResultCacheKey
+	result   *QueryResult
+	cachedAt time.Time
+}
+
+// NewMemoryResultCache returns a cache holding up to size results, the
+// least recently used being evicted first. Results expire after ttl, or
+// never when ttl is zero
+func NewMemoryResultCache(size int, ttl time.Duration) *MemoryResultCache {
+	return &MemoryResultCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[ResultCacheKey]*list.Element{},
+	}
+}
+
+// Get returns the result cached under the key, if any, not expired and not
+// older than maxAge
+func (m *MemoryResultCache) Get(key ResultCacheKey, maxAge time.Duration) (*QueryResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryResultEntry)
+	if m.ttl > 0 && m.now().Sub(entry.cachedAt) > m.ttl {
+		m.order.Remove(element)
+		delete(m.entries, key)
+		return nil, false
+	}
+	if maxAge > 0 && resultAge(entry.result, entry.cachedAt, m.now()) > maxAge {
+		return nil, false
+	}
+	m.order.MoveToFront(element)
+
+	return copyQueryResult(entry.result), true
+}
+
+// Set caches a copy of a result under the key, evicting the least recently
+// used result when the cache is full
+func (m *MemoryResultCache) Set(key ResultCacheKey, result *QueryResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoryResultEntry{key: key, result: copyQueryResult(result), cachedAt: m.now()}
+	if element, ok := m.entries[key]; ok {
+		element.Value = entry
+		m.order.MoveToFront(element)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+	for m.size > 0 && m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryResultEntry).key)
+	}
+
+	return nil
+}
+
+// Invalidate drops the results of the query for all parameter values
+func (m *MemoryResultCache) Invalidate(id QueryID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, element := range m.entries {
+		if key.QueryID == id {
+			m.order.Remove(element)
+			delete(m.entries, key)
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of results cached, expired ones included
+func (m *MemoryResultCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+// resultAge returns how long ago Redash computed a cached result, going by
+// when it was cached for results which do not say
+func resultAge(result *QueryResult, cachedAt time.Time, now time.Time) time.Duration {
+	if result.RetrievedAt != nil {
+		return now.Sub(*result.RetrievedAt)
+	}
+
+	return now.Sub(cachedAt)
+}
+
+// copyQueryResult returns a deep copy of a result, so a cache does not share
+// the rows it holds with its callers
+func copyQueryResult(result *QueryResult) *QueryResult {
+	copied := *result
+	if result.RetrievedAt != nil {
+		retrievedAt := *result.RetrievedAt
+		copied.RetrievedAt = &retrievedAt
+	}
+	if result.Data.Columns != nil {
+		copied.Data.Columns = append([]QueryResultColumn{}, result.Data.Columns...)
+	}
+	if result.Data.Rows != nil {
+		copied.Data.Rows = make([]map[string]interface{}, len(result.Data.Rows))
+		for i, row := range result.Data.Rows {
+			copied.Data.Rows[i] = copyValue(row).(map[string]interface{})
+		}
+	}
+
+	return &copied
+}
+
+// copyValue returns a deep copy of a decoded JSON value
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if value == nil {
+			return value
+		}
+		copied := make(map[string]interface{}, len(value))
+		for name, field := range value {
+			copied[name] = copyValue(field)
+		}
+		return copied
+	case []interface{}:
+		if value == nil {
+			return value
+		}
+		copied := make([]interface{}, len(value))
+		for i, element := range value {
+			copied[i] = copyValue(element)
+		}
+		return copied
+	}
+
+	return value
+}
+
+// FileResultCache is a ResultCache storing results as JSON files under a
+// directory, so they survive the process. Each query has a directory of
+// its own holding a file per version and set of parameter values
+type FileResultCache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+type fileResultEntry struct {
+	CachedAt time.Time    `json:"cached_at"`
+	Result   *QueryResult `json:"result"`
+}
+
+// NewFileResultCache returns a cache storing results under dir, which is
+// created when missing. Results expire after ttl, or never when ttl is zero
+func NewFileResultCache(dir string, ttl time.Duration) (*FileResultCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &FileResultCache{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+func (f *FileResultCache) queryDir(id QueryID) string {
+	return filepath.Join(f.dir, id.String())
+}
+
+func (f *FileResultCache) name(key ResultCacheKey) string {
+	return fmt.Sprintf("%d-%s", key.Version, key.Parameters)
+}
+
+func (f *FileResultCache) path(key ResultCacheKey) string {
+	return filepath.Join(f.queryDir(key.QueryID), f.name(key)+".json")
+}
+
+// Get returns the result stored under the key, if any, not expired and not
+// older than maxAge. Unreadable files are treated as missing
+func (f *FileResultCache) Get(key ResultCacheKey, maxAge time.Duration) (*QueryResult, bool) {
+	raw, err := ioutil.ReadFile(f.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	entry := fileResultEntry{}
+	if err := json.Unmarshal(raw, &entry); err != nil || entry.Result == nil {
+		return nil, false
+	}
+
+	if f.ttl > 0 && f.now().Sub(entry.CachedAt) > f.ttl {
+		os.Remove(f.path(key))
+		return nil, false
+	}
+	if maxAge > 0 && resultAge(entry.Result, entry.CachedAt, f.now()) > maxAge {
+		return nil, false
+	}
+
+	return entry.Result, true
+}
+
+// Set stores a result under the key. The file is written under another
+// name and renamed, so concurrent readers never see a partial result
+func (f *FileResultCache) Set(key ResultCacheKey, result *QueryResult) error {
+	raw, err := json.Marshal(fileResultEntry{CachedAt: f.now(), Result: result})
+	if err != nil {
+		return err
+	}
+
+	dir := f.queryDir(key.QueryID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, f.name(key)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+// Invalidate removes the results of the query for all parameter values
+func (f *FileResultCache) Invalidate(id QueryID) error {
+	return os.RemoveAll(f.queryDir(id))
+}
+
+type maxAgeKey struct{}
+
+// ContextWithMaxAge returns a context letting query runs be answered with a
+// result computed up to maxAge ago, by Redash or the client's ResultCache,
+// instead of running the query. It overrides Config.MaxAge, and zero always
+// runs the query, bypassing the ResultCache too
+func ContextWithMaxAge(ctx context.Context, maxAge time.Duration) context.Context {
+	return context.WithValue(ctx, maxAgeKey{}, maxAge)
+}
+
+// maxAge returns the max_age to run queries with, in seconds
+func (c *Client) maxAge(ctx context.Context) int {
+	maxAge, ok := ctx.Value(maxAgeKey{}).(time.Duration)
+	if !ok {
+		maxAge = c.Config.MaxAge
+	}
+
+	return int(maxAge / time.Second)
+}
+
+// InvalidateQueryResults drops the results of a Query from the client's
+// ResultCache, so the next RunQuery asks Redash again
+func (c *Client) InvalidateQueryResults(id QueryID) error {
+	if c.Config.ResultCache == nil {
+		return nil
+	}
+
+	return c.Config.ResultCache.Invalidate(id)
+}
+
+// cacheMaxAge returns the age of the oldest result the client's ResultCache
+// may answer a run with, zero when Config.MaxAge leaves it to the cache's
+// TTL. bypass is set when the context asks for a zero max age, so the query
+// always runs
+func (c *Client) cacheMaxAge(ctx context.Context) (maxAge time.Duration, bypass bool) {
+	if maxAge, ok := ctx.Value(maxAgeKey{}).(time.Duration); ok {
+		return maxAge, maxAge <= 0
+	}
+
+	return c.Config.MaxAge, false
+}
+
+// cachedResult returns the result of a run cached on the client side, if
+// any and not older than maxAge. A zero key means the run is not cached
+func (c *Client) cachedResult(key ResultCacheKey, maxAge time.Duration) (*QueryResult, bool) {
+	if c.Config.ResultCache == nil || key == (ResultCacheKey{}) {
+		return nil, false
+	}
+
+	result, ok := c.Config.ResultCache.Get(key, maxAge)
+	if ok {
+		c.logger().Debug("Query result cache hit", Field{"query_id", key.QueryID}, Field{"result_id", result.ID})
+	}
+
+	return result, ok
+}
+
+// cacheResult keeps the result of a run on the client side. Failing to do
+// so is logged and otherwise ignored, as the result itself is fine
+func (c *Client) cacheResult(key ResultCacheKey, result *QueryResult) {
+	if c.Config.ResultCache == nil || key == (ResultCacheKey{}) {
+		return
+	}
+
+	if err := c.Config.ResultCache.Set(key, result); err != nil {
+		c.logger().Warn("Could not cache query result", Field{"query_id", key.QueryID}, Field{"error", err.Error()})
+	}
+}
@@ -0,0 +1,260 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultCacheKey(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := newResultCacheKey(3, 1, map[string]interface{}{"channel": "web", "period": "d_last_7_days"})
+	assert.Nil(err)
+	b, _ := newResultCacheKey(3, 1, map[string]interface{}{"period": "d_last_7_days", "channel": "web"})
+	c, _ := newResultCacheKey(3, 1, map[string]interface{}{"channel": "mobile", "period": "d_last_7_days"})
+	d, _ := newResultCacheKey(4, 1, map[string]interface{}{"channel": "web", "period": "d_last_7_days"})
+	e, _ := newResultCacheKey(3, 2, map[string]interface{}{"channel": "web", "period": "d_last_7_days"})
+
+	assert.Equal(a, b)
+	assert.NotEqual(a, c)
+	assert.Equal(a.Parameters, d.Parameters)
+	assert.NotEqual(a, d)
+	assert.Equal(a.Parameters, e.Parameters)
+	assert.NotEqual(a, e)
+}
+
+func TestMemoryResultCache(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2022, 3, 14, 9, 30, 0, 0, time.UTC)
+	cache := NewMemoryResultCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	first := ResultCacheKey{QueryID: 1, Parameters: "a"}
+	second := ResultCacheKey{QueryID: 2, Parameters: "a"}
+	third := ResultCacheKey{QueryID: 2, Parameters: "b"}
+
+	assert.Nil(cache.Set(first, &QueryResult{ID: 1}))
+	assert.Nil(cache.Set(second, &QueryResult{ID: 2}))
+
+	// reading the first result makes the second the least recently used
+	result, ok := cache.Get(first, 0)
+	assert.True(ok)
+	assert.Equal(QueryResultID(1), result.ID)
+
+	assert.Nil(cache.Set(third, &QueryResult{ID: 3}))
+	assert.Equal(2, cache.Len())
+	_, ok = cache.Get(second, 0)
+	assert.False(ok)
+
+	// callers get copies, so changing them leaves the cache untouched
+	result.Data.Rows = append(result.Data.Rows, map[string]interface{}{"events": json.Number("1")})
+	stored := &QueryResult{ID: 4, Data: QueryResultData{Rows: []map[string]interface{}{{"events": json.Number("12")}}}}
+	assert.Nil(cache.Set(third, stored))
+	stored.Data.Rows[0]["events"] = json.Number("0")
+	result, _ = cache.Get(third, 0)
+	assert.Equal(json.Number("12"), result.Data.Rows[0]["events"])
+	result.Data.Rows[0]["events"] = json.Number("0")
+	result, _ = cache.Get(third, 0)
+	assert.Equal(json.Number("12"), result.Data.Rows[0]["events"])
+	result, _ = cache.Get(first, 0)
+	assert.Empty(result.Data.Rows)
+
+	assert.Nil(cache.Invalidate(2))
+	_, ok = cache.Get(third, 0)
+	assert.False(ok)
+	_, ok = cache.Get(first, 0)
+	assert.True(ok)
+
+	// results older than the max age are skipped, but kept for runs allowing
+	// older results
+	now = now.Add(30 * time.Second)
+	_, ok = cache.Get(first, 10*time.Second)
+	assert.False(ok)
+	_, ok = cache.Get(first, 45*time.Second)
+	assert.True(ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.Get(first, 0)
+	assert.False(ok)
+	assert.Equal(0, cache.Len())
+}
+
+func TestFileResultCache(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := filepath.Join(t.TempDir(), "results")
+	now := time.Date(2022, 3, 14, 9, 30, 0, 0, time.UTC)
+	cache, err := NewFileResultCache(dir, time.Hour)
+	assert.Nil(err)
+	cache.now = func() time.Time { return now }
+
+	key := ResultCacheKey{QueryID: 3, Version: 2, Parameters: "a1b2"}
+	retrievedAt := time.Date(2022, 3, 14, 9, 0, 0, 0, time.UTC)
+	assert.Nil(cache.Set(key, &QueryResult{
+		ID:          9,
+		RetrievedAt: &retrievedAt,
+		Data: QueryResultData{
+			Columns: []QueryResultColumn{{Name: "events", Type: "integer"}},
+			Rows:    []map[string]interface{}{{"events": float64(12)}},
+		},
+	}))
+
+	// another cache over the same directory, as in a later run
+	reopened, err := NewFileResultCache(dir, time.Hour)
+	assert.Nil(err)
+	reopened.now = func() time.Time { return now.Add(30 * time.Minute) }
+
+	// the result was computed an hour before, and cached half an hour before
+	_, ok := reopened.Get(key, 45*time.Minute)
+	assert.False(ok)
+	result, ok := reopened.Get(key, 0)
+	assert.True(ok)
+	assert.Equal(QueryResultID(9), result.ID)
+	assert.Equal(retrievedAt, *result.RetrievedAt)
+	assert.Equal(json.Number("12"), result.Data.Rows[0]["events"])
+
+	reopened.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, ok = reopened.Get(key, 0)
+	assert.False(ok)
+	_, err = os.Stat(filepath.Join(dir, "3", "2-a1b2.json"))
+	assert.True(os.IsNotExist(err))
+
+	assert.Nil(cache.Set(key, &QueryResult{ID: 9}))
+	assert.Nil(os.WriteFile(filepath.Join(dir, "3", "0-corrupt.json"), []byte("{"), 0600))
+	_, ok = cache.Get(ResultCacheKey{QueryID: 3, Parameters: "corrupt"}, 0)
+	assert.False(ok)
+
+	assert.Nil(cache.Invalidate(3))
+	_, ok = cache.Get(key, 0)
+	assert.False(ok)
+	assert.Nil(cache.Invalidate(3))
+}
+
+func TestRunQueryResultCache(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	// exportResult was retrieved at 09:30
+	now := time.Date(2022, 3, 14, 9, 40, 0, 0, time.UTC)
+	cache := NewMemoryResultCache(10, 0)
+	cache.now = func() time.Time { return now }
+	c, _ := NewClient(&Config{
+		RedashURI:   "https://com.acme/",
+		APIKey:      "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		MaxAge:      time.Hour,
+		ResultCache: cache,
+	})
+
+	var sent map[string]interface{}
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		captureExecution(&sent, exportResult))
+
+	ctx := context.Background()
+	result, err := c.RunQueryContext(ctx, 3, nil)
+	assert.Nil(err)
+	assert.Equal(QueryResultID(9), result.ID)
+	assert.Equal(float64(3600), sent["max_age"])
+
+	// the defaults are the values of the first run, so its result is reused
+	cached, err := c.RunQueryContext(ctx, 3, QueryParameters{"channel": Enum("web")})
+	assert.Nil(err)
+	assert.Equal(result, cached)
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+
+	// a zero max age runs the query again even though its result is cached
+	_, err = c.RunQueryContext(ContextWithMaxAge(ctx, 0), 3, nil)
+	assert.Nil(err)
+	assert.Equal(float64(0), sent["max_age"])
+	assert.Equal(2, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+
+	assert.Nil(c.InvalidateQueryResults(3))
+	_, err = c.RunQueryContext(ctx, 3, nil)
+	assert.Nil(err)
+	assert.Equal(3, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+
+	name := "Export v2"
+	_, err = c.UpdateQuery(3, &QueryUpdatePayload{Name: &name})
+	assert.Nil(err)
+	_, err = c.RunQueryContext(ctx, 3, nil)
+	assert.Nil(err)
+	assert.Equal(4, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+
+	// a query changed by someone else has a new version, whose results are
+	// not cached yet
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, strings.Replace(exportQuery, `"id": 3,`, `"id": 3, "version": 2,`, 1)))
+	_, err = c.RunQueryContext(ctx, 3, nil)
+	assert.Nil(err)
+	assert.Equal(5, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+	_, err = c.RunQueryContext(ctx, 3, nil)
+	assert.Nil(err)
+	assert.Equal(5, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+
+	// a result older than the max age is not used, though a run allowing
+	// older results still gets it
+	now = now.Add(time.Hour)
+	_, err = c.RunQueryContext(ContextWithMaxAge(ctx, 2*time.Hour), 3, nil)
+	assert.Nil(err)
+	assert.Equal(5, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+	_, err = c.RunQueryContext(ctx, 3, nil)
+	assert.Nil(err)
+	assert.Equal(6, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+}
+
+func TestRunQueryResultCacheWithoutMaxAge(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{
+		RedashURI:   "https://com.acme/",
+		APIKey:      "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		ResultCache: NewMemoryResultCache(10, time.Hour),
+	})
+
+	var sent map[string]interface{}
+	httpmock.RegisterResponder("GET", "https://com.acme/api/queries/3",
+		httpmock.NewStringResponder(200, exportQuery))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/queries/3/results",
+		captureExecution(&sent, exportResult))
+
+	// Redash runs the query, and the cache answers until its TTL expires
+	result, err := c.RunQuery(3, nil)
+	assert.Nil(err)
+	assert.Equal(float64(0), sent["max_age"])
+	cached, err := c.RunQuery(3, nil)
+	assert.Nil(err)
+	assert.Equal(result, cached)
+	assert.Equal(1, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+
+	_, err = c.RunQueryContext(ContextWithMaxAge(context.Background(), 0), 3, nil)
+	assert.Nil(err)
+	assert.Equal(2, httpmock.GetCallCountInfo()["POST https://com.acme/api/queries/3/results"])
+}
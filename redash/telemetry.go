@@ -0,0 +1,225 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// Every API call gets a span named after its method and route, e.g.
+// "GET /api/data_sources/{id}", carrying the attributes below, and its
+// duration and failures are recorded by the Meter with the same attributes,
+// except for the number of retries, which only the span carries. A call
+// lasts until its response body is closed
+const (
+	attrMethod    = "http.request.method"
+	attrStatus    = "http.response.status_code"
+	attrResource  = "redash.resource"
+	attrOperation = "redash.operation"
+	attrRetries   = "redash.retries"
+)
+
+// Tracer opens a span for every API call. Set Config.Tracer to the
+// OpenTelemetry adapter of the redashotel module, or to your own
+// implementation; by default nothing is traced
+type Tracer interface {
+	Start(ctx context.Context, name string, attributes ...Field) (context.Context, Span)
+}
+
+// Span is the span of a single API call
+type Span interface {
+	SetAttributes(attributes ...Field)
+	RecordError(err error)
+	End()
+}
+
+// Meter records the duration of every API call and counts the ones which
+// failed. Set Config.Meter to the OpenTelemetry adapter of the redashotel
+// module, or to your own implementation; by default nothing is recorded
+type Meter interface {
+	RecordDuration(ctx context.Context, duration time.Duration, attributes ...Field)
+	RecordError(ctx context.Context, attributes ...Field)
+}
+
+// NopTracer opens spans which record nothing
+type NopTracer struct{}
+
+// Start returns the context unchanged with a NopSpan
+func (NopTracer) Start(ctx context.Context, _ string, _ ...Field) (context.Context, Span) {
+	return ctx, NopSpan{}
+}
+
+// NopSpan records nothing
+type NopSpan struct{}
+
+// SetAttributes discards the attributes
+func (NopSpan) SetAttributes(...Field) {}
+
+// RecordError discards the error
+func (NopSpan) RecordError(error) {}
+
+// End does nothing
+func (NopSpan) End() {}
+
+// NopMeter discards every measurement
+type NopMeter struct{}
+
+// RecordDuration discards the duration
+func (NopMeter) RecordDuration(context.Context, time.Duration, ...Field) {}
+
+// RecordError discards the error
+func (NopMeter) RecordError(context.Context, ...Field) {}
+
+func (c *Client) tracer() Tracer {
+	if c.Config.Tracer == nil {
+		return NopTracer{}
+	}
+
+	return c.Config.Tracer
+}
+
+func (c *Client) meter() Meter {
+	if c.Config.Meter == nil {
+		return NopMeter{}
+	}
+
+	return c.Config.Meter
+}
+
+// apiCall is the telemetry of a single API call in flight
+type apiCall struct {
+	client     *Client
+	span       Span
+	start      time.Time
+	attributes []Field
+	roundTrips int32
+	once       sync.Once
+}
+
+type apiCallKey struct{}
+
+// startAPICall opens the span of an API call. The returned context carries
+// the span and the call, so middlewares and the transport see them
+func (c *Client) startAPICall(ctx context.Context, method, path string) (context.Context, *apiCall) {
+	resource, operation := routeOf(path)
+	call := &apiCall{
+		client: c,
+		start:  time.Now(),
+		attributes: []Field{
+			{attrMethod, method},
+			{attrResource, resource},
+			{attrOperation, operation},
+		},
+	}
+
+	ctx, call.span = c.tracer().Start(ctx, method+" "+operation, call.attributes...)
+
+	return context.WithValue(ctx, apiCallKey{}, call), call
+}
+
+// end closes the span of the API call and records its metrics, once only.
+// status is zero when no response was received
+func (call *apiCall) end(ctx context.Context, status int, err error) {
+	call.once.Do(func() {
+		attributes := call.attributes
+		if status != 0 {
+			attributes = append(attributes, Field{attrStatus, status})
+			call.span.SetAttributes(Field{attrStatus, status})
+		}
+
+		retries := int(atomic.LoadInt32(&call.roundTrips)) - 1
+		if retries < 0 {
+			retries = 0
+		}
+		call.span.SetAttributes(Field{attrRetries, retries})
+
+		meter := call.client.meter()
+		if err != nil {
+			call.span.RecordError(err)
+			meter.RecordError(ctx, attributes...)
+		}
+		meter.RecordDuration(ctx, time.Since(call.start), attributes...)
+
+		call.span.End()
+	})
+}
+
+// baseTransport sends requests to http.DefaultTransport at the end of the
+// middleware chain, counting the round trips of their API call so retries
+// made by RoundTripper middlewares are recorded
+type baseTransport struct{}
+
+func (baseTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if call, ok := request.Context().Value(apiCallKey{}).(*apiCall); ok {
+		atomic.AddInt32(&call.roundTrips, 1)
+	}
+
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+// callBody ends its API call when the response body is closed, so reading
+// the body counts towards the call
+type callBody struct {
+	io.ReadCloser
+	end func()
+}
+
+func (b *callBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.end()
+	return err
+}
+
+// routeOf returns the resource an API path addresses and its route, with
+// ids replaced by {id} to keep the span names and metric attributes low in
+// cardinality, e.g. "data_sources" and "/api/data_sources/{id}/pause". File
+// extensions are kept, as in "/api/queries/{id}/results/{id}.csv"
+func routeOf(path string) (string, string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		ext := filepath.Ext(segment)
+		if isPathID(strings.TrimSuffix(segment, ext)) {
+			segments[i] = "{id}" + ext
+		}
+	}
+
+	resource := ""
+	if len(segments) > 1 && segments[0] == "api" {
+		resource = segments[1]
+	}
+
+	return resource, "/" + strings.Join(segments, "/")
+}
+
+// isPathID returns true for numeric ids and for the hexadecimal ids of jobs
+func isPathID(segment string) bool {
+	if segment == "" {
+		return false
+	}
+
+	numeric := strings.IndexFunc(segment, func(r rune) bool { return !unicode.IsDigit(r) }) == -1
+	hexadecimal := len(segment) >= 16 && strings.IndexFunc(segment, func(r rune) bool {
+		return !unicode.Is(unicode.ASCII_Hex_Digit, r) && r != '-'
+	}) == -1
+
+	return numeric || hexadecimal
+}
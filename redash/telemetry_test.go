@@ -0,0 +1,256 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordedSpan is a span seen by a recordingTracer
+type recordedSpan struct {
+	name       string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...Field) {
+	for _, attribute := range attributes {
+		s.attributes[attribute.Key] = attribute.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type spanKey struct{}
+
+// recordingTracer and recordingMeter keep what the client reports
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attributes ...Field) (context.Context, Span) {
+	span := &recordedSpan{name: name, attributes: map[string]interface{}{}}
+	span.SetAttributes(attributes...)
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type recordingMeter struct {
+	mu        sync.Mutex
+	durations []time.Duration
+	errors    []map[string]interface{}
+}
+
+func (m *recordingMeter) RecordDuration(_ context.Context, duration time.Duration, _ ...Field) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations = append(m.durations, duration)
+}
+
+func (m *recordingMeter) RecordError(_ context.Context, attributes ...Field) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	recorded := map[string]interface{}{}
+	for _, attribute := range attributes {
+		recorded[attribute.Key] = attribute.Value
+	}
+	m.errors = append(m.errors, recorded)
+}
+
+// retryOnce retries a request once when Redash answers 503
+func retryOnce(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		response, err := next.RoundTrip(req)
+		if err == nil && response.StatusCode == 503 {
+			response.Body.Close()
+			return next.RoundTrip(req)
+		}
+		return response, err
+	})
+}
+
+func TestTelemetry(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracer, meter := &recordingTracer{}, &recordingMeter{}
+	c, _ := NewClient(&Config{
+		RedashURI: "https://com.acme/",
+		APIKey:    "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		Tracer:    tracer,
+		Meter:     meter,
+	})
+	c.Use(Middleware{RoundTripper: retryOnce})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1", func(req *http.Request) (*http.Response, error) {
+		if httpmock.GetCallCountInfo()["GET https://com.acme/api/data_sources/1"] == 1 {
+			return httpmock.NewStringResponse(503, ""), nil
+		}
+		return httpmock.NewStringResponse(200, redshiftDataSource), nil
+	})
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/2/pause",
+		httpmock.NewStringResponder(404, ""))
+
+	_, err := c.GetDataSource(1)
+	assert.Nil(err)
+	_, err = c.PauseDataSource(2, "Maintenance")
+	assert.EqualError(err, "HTTP Response: 404")
+
+	assert.Len(tracer.spans, 2)
+
+	assert.Equal("GET /api/data_sources/{id}", tracer.spans[0].name)
+	assert.Equal(map[string]interface{}{
+		"http.request.method":       "GET",
+		"redash.resource":           "data_sources",
+		"redash.operation":          "/api/data_sources/{id}",
+		"http.response.status_code": 200,
+		"redash.retries":            1,
+	}, tracer.spans[0].attributes)
+	assert.Nil(tracer.spans[0].err)
+	assert.True(tracer.spans[0].ended)
+
+	assert.Equal("POST /api/data_sources/{id}/pause", tracer.spans[1].name)
+	assert.Equal(404, tracer.spans[1].attributes["http.response.status_code"])
+	assert.Equal(0, tracer.spans[1].attributes["redash.retries"])
+	assert.EqualError(tracer.spans[1].err, "HTTP Response: 404")
+	assert.True(tracer.spans[1].ended)
+
+	assert.Len(meter.durations, 2)
+	assert.Len(meter.errors, 1)
+	assert.Equal(404, meter.errors[0]["http.response.status_code"])
+	assert.NotContains(meter.errors[0], "redash.retries")
+}
+
+func TestTelemetryAfterResponseError(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracer, meter := &recordingTracer{}, &recordingMeter{}
+	c, _ := NewClient(&Config{
+		RedashURI: "https://com.acme/",
+		APIKey:    "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		Tracer:    tracer,
+		Meter:     meter,
+	})
+	c.Use(Middleware{AfterResponse: func(response *http.Response) error {
+		if response.StatusCode == 429 {
+			return errors.New("Rate limited")
+		}
+		return nil
+	}})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(429, ""))
+
+	_, err := c.GetDataSource(1)
+	assert.EqualError(err, "Rate limited")
+	assert.Equal(429, tracer.spans[0].attributes["http.response.status_code"])
+	assert.Equal(429, meter.errors[0]["http.response.status_code"])
+}
+
+func TestTelemetrySpanCoversBody(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracer, meter := &recordingTracer{}, &recordingMeter{}
+	c, _ := NewClient(&Config{
+		RedashURI: "https://com.acme/",
+		APIKey:    "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		Tracer:    tracer,
+		Meter:     meter,
+	})
+
+	var seen *recordedSpan
+	c.Use(Middleware{BeforeRequest: func(req *http.Request) error {
+		seen, _ = req.Context().Value(spanKey{}).(*recordedSpan)
+		return nil
+	}})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, redshiftDataSource))
+
+	response, err := c.doRequestContext(context.Background(), http.MethodGet, "/api/data_sources/1", "", nil)
+	assert.Nil(err)
+	assert.Same(tracer.spans[0], seen)
+	assert.False(tracer.spans[0].ended)
+	assert.Empty(meter.durations)
+
+	response.Body.Close()
+	response.Body.Close()
+	assert.True(tracer.spans[0].ended)
+	assert.Len(meter.durations, 1)
+}
+
+func TestTelemetryDeleteEndsSpan(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracer := &recordingTracer{}
+	c, _ := NewClient(&Config{
+		RedashURI: "https://com.acme/",
+		APIKey:    "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		Tracer:    tracer,
+	})
+
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/groups/3",
+		httpmock.NewStringResponder(204, ""))
+	httpmock.RegisterResponder("DELETE", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(204, ""))
+
+	assert.Nil(c.DeleteGroup(3))
+	assert.Nil(c.DeleteDataSource(1))
+
+	assert.Len(tracer.spans, 2)
+	assert.True(tracer.spans[0].ended)
+	assert.True(tracer.spans[1].ended)
+}
+
+func TestRouteOf(t *testing.T) {
+	assert := assert.New(t)
+
+	resource, operation := routeOf("/api/data_sources/12/pause")
+	assert.Equal("data_sources", resource)
+	assert.Equal("/api/data_sources/{id}/pause", operation)
+
+	resource, operation = routeOf("/api/jobs/0b0a8f5e-8d7e-4e5b-b0a1-1e3c3b0c9d2f")
+	assert.Equal("jobs", resource)
+	assert.Equal("/api/jobs/{id}", operation)
+
+	resource, operation = routeOf("/api/queries/3/results/9.csv")
+	assert.Equal("queries", resource)
+	assert.Equal("/api/queries/{id}/results/{id}.csv", operation)
+
+	resource, operation = routeOf("/api/dashboards/sales")
+	assert.Equal("dashboards", resource)
+	assert.Equal("/api/dashboards/sales", operation)
+}
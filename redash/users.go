@@ -14,54 +14,144 @@
 package redash
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // UserList struct
 type UserList struct {
-	Count    int `json:"count"`
-	Page     int `json:"page"`
-	PageSize int `json:"page_size"`
-	Results  []struct {
-		AuthType            string    `json:"auth_type,omitempty"`
-		IsDisabled          bool      `json:"is_disabled,omitempty"`
-		UpdatedAt           time.Time `json:"updated_at,omitempty"`
-		ProfileImageURL     string    `json:"profile_image_url,omitempty"`
-		IsInvitationPending bool      `json:"is_invitation_pending,omitempty"`
-		Groups              []struct {
-			ID   int    `json:"id,omitempty"`
-			Name string `json:"name,omitempty"`
-		} `json:"groups,omitempty"`
-		ID              int         `json:"id,omitempty"`
-		Name            string      `json:"name,omitempty"`
-		CreatedAt       time.Time   `json:"created_at,omitempty"`
-		DisabledAt      interface{} `json:"disabled_at,omitempty"`
-		IsEmailVerified bool        `json:"is_email_verified,omitempty"`
-		ActiveAt        time.Time   `json:"active_at,omitempty"`
-		Email           string      `json:"email,omitempty"`
-	} `json:"results,omitempty"`
-}
-
-// User representation
+	Count    int           `json:"count"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	Results  []UserSummary `json:"results,omitempty"`
+}
+
+// GroupRef identifies a group a user belongs to
+type GroupRef struct {
+	ID   GroupID `json:"id,omitempty"`
+	Name string  `json:"name,omitempty"`
+}
+
+// UserSummary is a user as listed by GetUsers and SearchUsers, which
+// describe groups by reference rather than by id
+type UserSummary struct {
+	AuthType            string     `json:"auth_type,omitempty"`
+	IsDisabled          bool       `json:"is_disabled,omitempty"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
+	ProfileImageURL     string     `json:"profile_image_url,omitempty"`
+	IsInvitationPending bool       `json:"is_invitation_pending,omitempty"`
+	Groups              []GroupRef `json:"groups,omitempty"`
+	ID                  UserID     `json:"id,omitempty"`
+	Name                string     `json:"name,omitempty"`
+	CreatedAt           *time.Time `json:"created_at,omitempty"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	IsEmailVerified     bool       `json:"is_email_verified,omitempty"`
+	ActiveAt            *time.Time `json:"active_at,omitempty"`
+	Email               string     `json:"email,omitempty"`
+}
+
+// User representation. Timestamps are nil when Redash has none to report
 type User struct {
-	AuthType            string      `json:"auth_type,omitempty"`
-	IsDisabled          bool        `json:"is_disabled,omitempty"`
-	UpdatedAt           time.Time   `json:"updated_at,omitempty"`
-	ProfileImageURL     string      `json:"profile_image_url,omitempty"`
-	IsInvitationPending bool        `json:"is_invitation_pending,omitempty"`
-	Groups              []int       `json:"groups,omitempty"`
-	ID                  int         `json:"id,omitempty"`
-	Name                string      `json:"name,omitempty"`
-	CreatedAt           time.Time   `json:"created_at,omitempty"`
-	DisabledAt          interface{} `json:"disabled_at,omitempty"`
-	IsEmailVerified     bool        `json:"is_email_verified,omitempty"`
-	ActiveAt            time.Time   `json:"active_at,omitempty"`
-	Email               string      `json:"email,omitempty"`
+	AuthType            string     `json:"auth_type,omitempty"`
+	IsDisabled          bool       `json:"is_disabled,omitempty"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
+	ProfileImageURL     string     `json:"profile_image_url,omitempty"`
+	IsInvitationPending bool       `json:"is_invitation_pending,omitempty"`
+	Groups              []GroupID  `json:"groups,omitempty"`
+	ID                  UserID     `json:"id,omitempty"`
+	Name                string     `json:"name,omitempty"`
+	CreatedAt           *time.Time `json:"created_at,omitempty"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	IsEmailVerified     bool       `json:"is_email_verified,omitempty"`
+	ActiveAt            *time.Time `json:"active_at,omitempty"`
+	Email               string     `json:"email,omitempty"`
+
+	// Extra holds the fields Redash sent which User does not declare
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the fields Redash sends which User does not declare
+// in Extra
+func (u *User) UnmarshalJSON(data []byte) error {
+	type user User
+	extra, err := unmarshalWithExtra(data, (*user)(u))
+	if err != nil {
+		return err
+	}
+
+	u.Extra = extra
+	return nil
+}
+
+// MarshalJSON sends the fields kept in Extra back along with the declared ones
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return marshalWithExtra(user(u), u.Extra)
+}
+
+func (u User) extraFields() map[string]json.RawMessage {
+	return u.Extra
+}
+
+// GroupIDs returns the ids of the groups the user belongs to
+func (s *UserSummary) GroupIDs() []GroupID {
+	ids := []GroupID{}
+	for _, group := range s.Groups {
+		ids = append(ids, group.ID)
+	}
+
+	return ids
+}
+
+// User converts the summary into a User
+func (s *UserSummary) User() *User {
+	return &User{
+		AuthType:            s.AuthType,
+		IsDisabled:          s.IsDisabled,
+		UpdatedAt:           s.UpdatedAt,
+		ProfileImageURL:     s.ProfileImageURL,
+		IsInvitationPending: s.IsInvitationPending,
+		Groups:              s.GroupIDs(),
+		ID:                  s.ID,
+		Name:                s.Name,
+		CreatedAt:           s.CreatedAt,
+		DisabledAt:          s.DisabledAt,
+		IsEmailVerified:     s.IsEmailVerified,
+		ActiveAt:            s.ActiveAt,
+		Email:               s.Email,
+	}
+}
+
+// Summary converts the user into a UserSummary. As a User only knows the ids
+// of its groups, the names of the group references are left empty
+func (u *User) Summary() *UserSummary {
+	groups := []GroupRef{}
+	for _, id := range u.Groups {
+		groups = append(groups, GroupRef{ID: id})
+	}
+
+	return &UserSummary{
+		AuthType:            u.AuthType,
+		IsDisabled:          u.IsDisabled,
+		UpdatedAt:           u.UpdatedAt,
+		ProfileImageURL:     u.ProfileImageURL,
+		IsInvitationPending: u.IsInvitationPending,
+		Groups:              groups,
+		ID:                  u.ID,
+		Name:                u.Name,
+		CreatedAt:           u.CreatedAt,
+		DisabledAt:          u.DisabledAt,
+		IsEmailVerified:     u.IsEmailVerified,
+		ActiveAt:            u.ActiveAt,
+		Email:               u.Email,
+	}
 }
 
 // UserCreatePayload struct for mutating users.
@@ -70,21 +160,35 @@ type UserCreatePayload struct {
 	Email string `json:"email"`
 }
 
-// UserUpdatePayload struct for mutating users.
+// UserUpdatePayload struct for mutating users. Every field is sent, so
+// Groups must hold all the groups the user should remain a member of
 type UserUpdatePayload struct {
-	Name   string `json:"name"`
-	Email  string `json:"email"`
-	Groups []int  `json:"group_ids"`
+	Name   string    `json:"name"`
+	Email  string    `json:"email"`
+	Groups []GroupID `json:"group_ids"`
+}
+
+// UserPatchPayload struct for partially mutating users. Only the fields set
+// are sent, so a name can be changed without knowing the user's groups
+type UserPatchPayload struct {
+	Name   *string    `json:"name,omitempty"`
+	Email  *string    `json:"email,omitempty"`
+	Groups *[]GroupID `json:"group_ids,omitempty"`
 }
 
 //GetUsers returns a paginated list of users
 func (c *Client) GetUsers(page, pageSize int) (*UserList, error) {
+	return c.GetUsersContext(context.Background(), page, pageSize)
+}
+
+// GetUsersContext is GetUsers bound to the given context
+func (c *Client) GetUsersContext(ctx context.Context, page, pageSize int) (*UserList, error) {
 	path := "/api/users"
 
 	query := url.Values{}
 	query.Add("page", strconv.Itoa(page))
 	query.Add("page_size", strconv.Itoa(pageSize))
-	response, err := c.get(path, query)
+	response, err := c.getContext(ctx, path, query)
 
 	if err != nil {
 		return nil, err
@@ -103,11 +207,16 @@ func (c *Client) GetUsers(page, pageSize int) (*UserList, error) {
 }
 
 //GetUser gets a specific User
-func (c *Client) GetUser(id int) (*User, error) {
-	path := "/api/users/" + strconv.Itoa(id)
+func (c *Client) GetUser(id UserID) (*User, error) {
+	return c.GetUserContext(context.Background(), id)
+}
+
+// GetUserContext is GetUser bound to the given context
+func (c *Client) GetUserContext(ctx context.Context, id UserID) (*User, error) {
+	path := "/api/users/" + id.String()
 
 	query := url.Values{}
-	response, err := c.get(path, query)
+	response, err := c.getContext(ctx, path, query)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +229,7 @@ func (c *Client) GetUser(id int) (*User, error) {
 
 	user := User{}
 
-	err = json.Unmarshal(body, &user)
+	err = c.unmarshal(body, &user)
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +239,11 @@ func (c *Client) GetUser(id int) (*User, error) {
 
 // CreateUser creates a new Redash user
 func (c *Client) CreateUser(userCreatePayload *UserCreatePayload) (*User, error) {
+	return c.CreateUserContext(context.Background(), userCreatePayload)
+}
+
+// CreateUserContext is CreateUser bound to the given context
+func (c *Client) CreateUserContext(ctx context.Context, userCreatePayload *UserCreatePayload) (*User, error) {
 	path := "/api/users"
 
 	payload, err := json.Marshal(userCreatePayload)
@@ -138,7 +252,7 @@ func (c *Client) CreateUser(userCreatePayload *UserCreatePayload) (*User, error)
 	}
 
 	query := url.Values{}
-	response, err := c.post(path, string(payload), query)
+	response, err := c.postContext(ctx, path, string(payload), query)
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +265,7 @@ func (c *Client) CreateUser(userCreatePayload *UserCreatePayload) (*User, error)
 
 	user := User{}
 
-	err = json.Unmarshal(body, &user)
+	err = c.unmarshal(body, &user)
 	if err != nil {
 		return nil, err
 	}
@@ -160,8 +274,13 @@ func (c *Client) CreateUser(userCreatePayload *UserCreatePayload) (*User, error)
 }
 
 // UpdateUser updates an existing Redash user
-func (c *Client) UpdateUser(id int, userUpdatePayload *UserUpdatePayload) (*User, error) {
-	path := "/api/users/" + strconv.Itoa(id)
+func (c *Client) UpdateUser(id UserID, userUpdatePayload *UserUpdatePayload) (*User, error) {
+	return c.UpdateUserContext(context.Background(), id, userUpdatePayload)
+}
+
+// UpdateUserContext is UpdateUser bound to the given context
+func (c *Client) UpdateUserContext(ctx context.Context, id UserID, userUpdatePayload *UserUpdatePayload) (*User, error) {
+	path := "/api/users/" + id.String()
 
 	payload, err := json.Marshal(userUpdatePayload)
 	if err != nil {
@@ -169,7 +288,44 @@ func (c *Client) UpdateUser(id int, userUpdatePayload *UserUpdatePayload) (*User
 	}
 
 	query := url.Values{}
-	response, err := c.post(path, string(payload), query)
+	response, err := c.postContext(ctx, path, string(payload), query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	user := User{}
+
+	err = c.unmarshal(body, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// PatchUser updates the fields of an existing Redash user which are set in
+// the payload, leaving the others untouched
+func (c *Client) PatchUser(id UserID, userPatchPayload *UserPatchPayload) (*User, error) {
+	return c.PatchUserContext(context.Background(), id, userPatchPayload)
+}
+
+// PatchUserContext is PatchUser bound to the given context
+func (c *Client) PatchUserContext(ctx context.Context, id UserID, userPatchPayload *UserPatchPayload) (*User, error) {
+	path := "/api/users/" + id.String()
+
+	payload, err := json.Marshal(userPatchPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	response, err := c.postContext(ctx, path, string(payload), query)
 	if err != nil {
 		return nil, err
 	}
@@ -182,7 +338,7 @@ func (c *Client) UpdateUser(id int, userUpdatePayload *UserUpdatePayload) (*User
 
 	user := User{}
 
-	err = json.Unmarshal(body, &user)
+	err = c.unmarshal(body, &user)
 	if err != nil {
 		return nil, err
 	}
@@ -191,11 +347,16 @@ func (c *Client) UpdateUser(id int, userUpdatePayload *UserUpdatePayload) (*User
 }
 
 //DisableUser disables an active user.
-func (c *Client) DisableUser(id int) error {
-	path := "/api/users/" + strconv.Itoa(id) + "/disable"
+func (c *Client) DisableUser(id UserID) error {
+	return c.DisableUserContext(context.Background(), id)
+}
+
+// DisableUserContext is DisableUser bound to the given context
+func (c *Client) DisableUserContext(ctx context.Context, id UserID) error {
+	path := "/api/users/" + id.String() + "/disable"
 
 	query := url.Values{}
-	response, err := c.post(path, "", query)
+	response, err := c.postContext(ctx, path, "", query)
 	if err != nil {
 		return err
 	}
@@ -211,11 +372,16 @@ func (c *Client) DisableUser(id int) error {
 
 //SearchUsers finds a list of users matching a string (searches `name` and `email` fields)
 func (c *Client) SearchUsers(term string) (*UserList, error) {
+	return c.SearchUsersContext(context.Background(), term)
+}
+
+// SearchUsersContext is SearchUsers bound to the given context
+func (c *Client) SearchUsersContext(ctx context.Context, term string) (*UserList, error) {
 	path := "/api/users"
 
 	query := url.Values{}
 	query.Add("q", term)
-	response, err := c.get(path, query)
+	response, err := c.getContext(ctx, path, query)
 
 	if err != nil {
 		return nil, err
@@ -233,19 +399,125 @@ func (c *Client) SearchUsers(term string) (*UserList, error) {
 	return &users, nil
 }
 
-// GetUserByEmail returns a single  user from their email address
+// UserLookupOptions struct. Disabled users and users who have not accepted
+// their invitation yet are only matched when included
+type UserLookupOptions struct {
+	IncludeDisabled bool
+	IncludePending  bool
+}
+
+// searchAllUsers returns every user matching a term, reading all pages of
+// results. disabled selects disabled users instead of enabled ones
+func (c *Client) searchAllUsers(ctx context.Context, term string, disabled bool, includePending bool) (*UserList, error) {
+	path := "/api/users"
+	users := UserList{}
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Add("q", term)
+		query.Add("page", strconv.Itoa(page))
+		query.Add("page_size", strconv.Itoa(maxPageSize))
+		if disabled {
+			query.Add("disabled", "true")
+		}
+		if !includePending {
+			query.Add("pending", "false")
+		}
+
+		response, err := c.getContext(ctx, path, query)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		userList := UserList{}
+		err = json.Unmarshal(body, &userList)
+		if err != nil {
+			return nil, err
+		}
+
+		users.Count = userList.Count
+		users.Results = append(users.Results, userList.Results...)
+		if len(userList.Results) == 0 || len(users.Results) >= userList.Count {
+			return &users, nil
+		}
+	}
+}
+
+// FindUserByEmail returns a single user from their email address, compared
+// case-insensitively. Unlike SearchUsers it reads every page of results. The
+// error wraps ErrNotFound when no user matches
+func (c *Client) FindUserByEmail(email string, options *UserLookupOptions) (*User, error) {
+	return c.FindUserByEmailContext(context.Background(), email, options)
+}
+
+// FindUserByEmailContext is FindUserByEmail bound to the given context
+func (c *Client) FindUserByEmailContext(ctx context.Context, email string, options *UserLookupOptions) (*User, error) {
+	if options == nil {
+		options = &UserLookupOptions{}
+	}
+
+	searches := []bool{false}
+	if options.IncludeDisabled {
+		searches = append(searches, true)
+	}
+
+	for _, disabled := range searches {
+		results, err := c.searchAllUsers(ctx, email, disabled, options.IncludePending)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results.Results {
+			if result.Email != "" && strings.EqualFold(result.Email, email) {
+				return c.GetUserContext(ctx, result.ID)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("No user found with email address %s: %w", email, ErrNotFound)
+}
+
+// GetUserByEmail returns a single enabled user from their email address,
+// whether or not they accepted their invitation. The error wraps ErrNotFound
+// when no user matches
 func (c *Client) GetUserByEmail(email string) (*User, error) {
+	return c.GetUserByEmailContext(context.Background(), email)
+}
 
-	results, err := c.SearchUsers(email)
+// GetUserByEmailContext is GetUserByEmail bound to the given context
+func (c *Client) GetUserByEmailContext(ctx context.Context, email string) (*User, error) {
+	return c.FindUserByEmailContext(ctx, email, &UserLookupOptions{IncludePending: true})
+}
+
+// EnsureUser returns the user with the payload's email address, including
+// disabled and pending ones, creating them when missing and renaming them
+// when their name differs
+func (c *Client) EnsureUser(userCreatePayload *UserCreatePayload) (*User, error) {
+	return c.EnsureUserContext(context.Background(), userCreatePayload)
+}
+
+// EnsureUserContext is EnsureUser bound to the given context
+func (c *Client) EnsureUserContext(ctx context.Context, userCreatePayload *UserCreatePayload) (*User, error) {
+	user, err := c.FindUserByEmailContext(ctx, userCreatePayload.Email, &UserLookupOptions{
+		IncludeDisabled: true,
+		IncludePending:  true,
+	})
+	if errors.Is(err, ErrNotFound) {
+		return c.CreateUserContext(ctx, userCreatePayload)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	for _, result := range results.Results {
-		if result.Email != "" && result.Email == email {
-			return c.GetUser(result.ID)
-		}
+	if userCreatePayload.Name == "" || user.Name == userCreatePayload.Name {
+		return user, nil
 	}
 
-	return nil, fmt.Errorf("No user found with email address: %s", email)
+	return c.PatchUserContext(ctx, user.ID, &UserPatchPayload{Name: String(userCreatePayload.Name)})
 }
@@ -14,6 +14,10 @@
 package redash
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
 	"testing"
 
 	"github.com/jarcoal/httpmock"
@@ -33,7 +37,7 @@ func TestGetUser(t *testing.T) {
 	user, err := c.GetUser(1)
 	assert.Nil(err)
 
-	assert.Equal(1, user.ID)
+	assert.Equal(UserID(1), user.ID)
 	assert.Equal("Existing User", user.Name)
 }
 
@@ -55,7 +59,7 @@ func TestCreateUser(t *testing.T) {
 	user, err := c.CreateUser(&userPayload)
 	assert.Nil(err)
 
-	assert.Equal(2, user.ID)
+	assert.Equal(UserID(2), user.ID)
 	assert.Equal("New User", user.Name)
 }
 
@@ -72,17 +76,42 @@ func TestUpdateUser(t *testing.T) {
 	userPayload := UserUpdatePayload{
 		Name:   "New User Updated",
 		Email:  "test-update@email.com",
-		Groups: []int{2, 3, 4},
+		Groups: []GroupID{2, 3, 4},
 	}
 
 	user, err := c.UpdateUser(2, &userPayload)
 	assert.Nil(err)
 
-	assert.Equal(2, user.ID)
+	assert.Equal(UserID(2), user.ID)
 	assert.Equal("New User Updated", user.Name)
 	assert.Equal("test-update@email.com", user.Email)
 }
 
+func TestPatchUser(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	var sent string
+	httpmock.RegisterResponder("POST", "https://com.acme/api/users/2",
+		func(req *http.Request) (*http.Response, error) {
+			raw, _ := ioutil.ReadAll(req.Body)
+			sent = string(raw)
+			return httpmock.NewStringResponse(200, `{"id": 2, "name": "New User Updated", "groups": [2, 3]}`), nil
+		})
+
+	user, err := c.PatchUser(2, &UserPatchPayload{Name: String("New User Updated")})
+	assert.Nil(err)
+	assert.Equal(`{"name":"New User Updated"}`, sent)
+	assert.Equal([]GroupID{2, 3}, user.Groups)
+
+	_, err = c.PatchUser(2, &UserPatchPayload{Groups: GroupIDs()})
+	assert.Nil(err)
+	assert.Equal(`{"group_ids":[]}`, sent)
+}
+
 func TestGetUserByEmail(t *testing.T) {
 	assert := assert.New(t)
 	httpmock.Activate()
@@ -90,7 +119,7 @@ func TestGetUserByEmail(t *testing.T) {
 
 	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
 
-	httpmock.RegisterResponder("GET", "https://com.acme/api/users?q=t%C3%ABst%40email.com",
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users?page=1&page_size=250&q=t%C3%ABst%40email.com",
 		httpmock.NewStringResponder(200, `{"count": 1, "page": 1, "page_size": 25, "results": [ {"id": 1, "name": "Existing User", "email": "tëst@email.com"} ]}`))
 
 	httpmock.RegisterResponder("GET", "https://com.acme/api/users/1",
@@ -99,14 +128,91 @@ func TestGetUserByEmail(t *testing.T) {
 	user, err := c.GetUserByEmail("tëst@email.com")
 	assert.Nil(err)
 
-	assert.Equal(1, user.ID)
+	assert.Equal(UserID(1), user.ID)
 	assert.Equal("tëst@email.com", user.Email)
 
-	httpmock.RegisterResponder("GET", "https://com.acme/api/users?q=tëst-not-found@email.com",
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users?page=1&page_size=250&q=t%C3%ABst-not-found%40email.com",
 		httpmock.NewStringResponder(200, `{"count": 0, "page": 1, "page_size": 25, "results": []}`))
 
 	user, err = c.GetUserByEmail("tëst-not-found@email.com")
 	assert.NotNil(err)
+	assert.True(errors.Is(err, ErrNotFound))
+}
+
+func TestFindUserByEmail(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users?page=1&page_size=250&pending=false&q=jane%40acme.com",
+		httpmock.NewStringResponder(200, `{"count": 3, "page": 1, "page_size": 250, "results": [{"id": 1, "email": "jane@acme.com.au"}, {"id": 2, "email": "mary.jane@acme.com"}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users?page=2&page_size=250&pending=false&q=jane%40acme.com",
+		httpmock.NewStringResponder(200, `{"count": 3, "page": 2, "page_size": 250, "results": [{"id": 3, "email": "Jane@Acme.com"}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users/3",
+		httpmock.NewStringResponder(200, `{"id": 3, "name": "Jane", "email": "Jane@Acme.com"}`))
+
+	user, err := c.FindUserByEmail("jane@acme.com", nil)
+	assert.Nil(err)
+	assert.Equal(UserID(3), user.ID)
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users?page=1&page_size=250&q=john%40acme.com",
+		httpmock.NewStringResponder(200, `{"count": 0, "page": 1, "page_size": 250, "results": []}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users?disabled=true&page=1&page_size=250&q=john%40acme.com",
+		httpmock.NewStringResponder(200, `{"count": 1, "page": 1, "page_size": 250, "results": [{"id": 4, "email": "john@acme.com", "is_disabled": true}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users/4",
+		httpmock.NewStringResponder(200, `{"id": 4, "name": "John", "email": "john@acme.com", "is_disabled": true}`))
+
+	_, err = c.GetUserByEmail("john@acme.com")
+	assert.True(errors.Is(err, ErrNotFound))
+
+	user, err = c.FindUserByEmail("john@acme.com", &UserLookupOptions{IncludeDisabled: true, IncludePending: true})
+	assert.Nil(err)
+	assert.Equal(UserID(4), user.ID)
+	assert.True(user.IsDisabled)
+}
+
+func TestEnsureUser(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users?page=1&page_size=250&q=jane%40acme.com",
+		httpmock.NewStringResponder(200, `{"count": 1, "page": 1, "page_size": 250, "results": [{"id": 1, "email": "jane@acme.com"}]}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users/1",
+		httpmock.NewStringResponder(200, `{"id": 1, "name": "Jane", "email": "jane@acme.com", "groups": [1, 2]}`))
+
+	var updated map[string]interface{}
+	httpmock.RegisterResponder("POST", "https://com.acme/api/users/1",
+		func(req *http.Request) (*http.Response, error) {
+			raw, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(raw, &updated)
+			return httpmock.NewStringResponse(200, `{"id": 1, "name": "Jane Doe", "email": "jane@acme.com", "groups": [1, 2]}`), nil
+		})
+
+	user, err := c.EnsureUser(&UserCreatePayload{Name: "Jane", Email: "jane@acme.com"})
+	assert.Nil(err)
+	assert.Equal(UserID(1), user.ID)
+	assert.Equal(0, httpmock.GetCallCountInfo()["POST https://com.acme/api/users/1"])
+
+	user, err = c.EnsureUser(&UserCreatePayload{Name: "Jane Doe", Email: "jane@acme.com"})
+	assert.Nil(err)
+	assert.Equal("Jane Doe", user.Name)
+	assert.Equal(map[string]interface{}{"name": "Jane Doe"}, updated)
+
+	for _, disabled := range []string{"", "disabled=true&"} {
+		httpmock.RegisterResponder("GET", "https://com.acme/api/users?"+disabled+"page=1&page_size=250&q=john%40acme.com",
+			httpmock.NewStringResponder(200, `{"count": 0, "page": 1, "page_size": 250, "results": []}`))
+	}
+	httpmock.RegisterResponder("POST", "https://com.acme/api/users",
+		httpmock.NewStringResponder(200, `{"id": 2, "name": "John", "email": "john@acme.com"}`))
+
+	user, err = c.EnsureUser(&UserCreatePayload{Name: "John", Email: "john@acme.com"})
+	assert.Nil(err)
+	assert.Equal(UserID(2), user.ID)
 }
 
 func TestDisableUser(t *testing.T) {
@@ -123,3 +229,46 @@ func TestDisableUser(t *testing.T) {
 
 	assert.Nil(err)
 }
+
+func TestGetUsers(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	c, _ := NewClient(&Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/users?page=1&page_size=25",
+		httpmock.NewStringResponder(200, `{"count": 1, "page": 1, "page_size": 25, "results": [{
+			"id": 1,
+			"name": "Jane",
+			"email": "jane@acme.com",
+			"groups": [{"id": 1, "name": "admin"}, {"id": 2, "name": "default"}],
+			"created_at": "2022-09-21T10:00:00.000Z",
+			"disabled_at": null,
+			"active_at": "2022-09-23T08:30:00+00:00"
+		}]}`))
+
+	users, err := c.GetUsers(1, 25)
+	assert.Nil(err)
+
+	summary := users.Results[0]
+	assert.Equal([]GroupRef{{ID: 1, Name: "admin"}, {ID: 2, Name: "default"}}, summary.Groups)
+	assert.Nil(summary.DisabledAt)
+	assert.Nil(summary.UpdatedAt)
+	assert.Equal(2022, summary.CreatedAt.Year())
+
+	user := summary.User()
+	assert.Equal(UserID(1), user.ID)
+	assert.Equal([]GroupID{1, 2}, user.Groups)
+	assert.Equal(summary.ActiveAt, user.ActiveAt)
+
+	assert.Equal([]GroupRef{{ID: 1}, {ID: 2}}, user.Summary().Groups)
+}
+
+func TestUserOmitsMissingTimestamps(t *testing.T) {
+	assert := assert.New(t)
+
+	payload, err := json.Marshal(User{ID: 1, Name: "Jane"})
+	assert.Nil(err)
+	assert.Equal(`{"id":1,"name":"Jane"}`, string(payload))
+}
@@ -0,0 +1,135 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package redashotel adapts OpenTelemetry to the Tracer and Meter of the
+// redash client
+package redashotel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/snowplow-devops/redash-client-go/redash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the client to OpenTelemetry
+const instrumentationName = "github.com/snowplow-devops/redash-client-go/redash"
+
+// The metrics recorded for API calls
+const (
+	metricDuration = "redash.client.request.duration"
+	metricErrors   = "redash.client.request.errors"
+)
+
+// tracer adapts a trace.Tracer to redash.Tracer
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns a redash.Tracer opening client spans with the given
+// TracerProvider
+func NewTracer(provider trace.TracerProvider) redash.Tracer {
+	return &tracer{tracer: provider.Tracer(instrumentationName)}
+}
+
+func (t *tracer) Start(ctx context.Context, name string, attributes ...redash.Field) (context.Context, redash.Span) {
+	ctx, s := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributesOf(attributes)...))
+
+	return ctx, &span{span: s}
+}
+
+// span adapts a trace.Span to redash.Span
+type span struct {
+	span trace.Span
+}
+
+func (s *span) SetAttributes(attributes ...redash.Field) {
+	s.span.SetAttributes(attributesOf(attributes)...)
+}
+
+func (s *span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *span) End() {
+	s.span.End()
+}
+
+// meter adapts the instruments of a metric.Meter to redash.Meter
+type meter struct {
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+}
+
+// NewMeter returns a redash.Meter recording the duration of API calls in
+// the redash.client.request.duration histogram and their failures in the
+// redash.client.request.errors counter of the given MeterProvider
+func NewMeter(provider metric.MeterProvider) (redash.Meter, error) {
+	m := provider.Meter(instrumentationName)
+
+	duration, err := m.Float64Histogram(metricDuration,
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of Redash API calls"))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating metric %s: %w", metricDuration, err)
+	}
+
+	errors, err := m.Int64Counter(metricErrors,
+		metric.WithDescription("Redash API calls which failed"))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating metric %s: %w", metricErrors, err)
+	}
+
+	return &meter{duration: duration, errors: errors}, nil
+}
+
+func (m *meter) RecordDuration(ctx context.Context, duration time.Duration, attributes ...redash.Field) {
+	m.duration.Record(ctx, duration.Seconds(), metric.WithAttributes(attributesOf(attributes)...))
+}
+
+func (m *meter) RecordError(ctx context.Context, attributes ...redash.Field) {
+	m.errors.Add(ctx, 1, metric.WithAttributes(attributesOf(attributes)...))
+}
+
+// attributesOf converts fields to attributes, formatting the values of
+// types OpenTelemetry has no attribute for
+func attributesOf(fields []redash.Field) []attribute.KeyValue {
+	attributes := make([]attribute.KeyValue, 0, len(fields))
+	for _, field := range fields {
+		key := attribute.Key(field.Key)
+		switch v := field.Value.(type) {
+		case string:
+			attributes = append(attributes, key.String(v))
+		case int:
+			attributes = append(attributes, key.Int(v))
+		case int64:
+			attributes = append(attributes, key.Int64(v))
+		case float64:
+			attributes = append(attributes, key.Float64(v))
+		case bool:
+			attributes = append(attributes, key.Bool(v))
+		default:
+			attributes = append(attributes, key.String(fmt.Sprint(v)))
+		}
+	}
+
+	return attributes
+}
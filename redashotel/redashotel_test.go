@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package redashotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/snowplow-devops/redash-client-go/redash"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTelemetry(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	reader := sdkmetric.NewManualReader()
+	meter, err := NewMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	assert.Nil(err)
+
+	c, _ := redash.NewClient(&redash.Config{
+		RedashURI: "https://com.acme/",
+		APIKey:    "ApIkEyApIkEyApIkEyApIkEyApIkEy",
+		Tracer:    NewTracer(tracerProvider),
+		Meter:     meter,
+	})
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/1",
+		httpmock.NewStringResponder(200, `{"id": 1, "name": "Warehouse", "type": "redshift"}`))
+	httpmock.RegisterResponder("POST", "https://com.acme/api/data_sources/2/pause",
+		httpmock.NewStringResponder(404, ""))
+
+	ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "export")
+	_, err = c.GetDataSourceContext(ctx, 1)
+	assert.Nil(err)
+	parent.End()
+	_, err = c.PauseDataSource(2, "Maintenance")
+	assert.EqualError(err, "HTTP Response: 404")
+
+	spans := exporter.GetSpans()
+	assert.Len(spans, 3)
+
+	assert.Equal("GET /api/data_sources/{id}", spans[0].Name)
+	assert.Equal(trace.SpanKindClient, spans[0].SpanKind)
+	assert.Equal(codes.Unset, spans[0].Status.Code)
+	assert.Equal(parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.ElementsMatch([]attribute.KeyValue{
+		attribute.String("http.request.method", "GET"),
+		attribute.String("redash.resource", "data_sources"),
+		attribute.String("redash.operation", "/api/data_sources/{id}"),
+		attribute.Int("http.response.status_code", 200),
+		attribute.Int("redash.retries", 0),
+	}, spans[0].Attributes)
+
+	assert.Equal("POST /api/data_sources/{id}/pause", spans[2].Name)
+	assert.Equal(codes.Error, spans[2].Status.Code)
+	assert.Contains(spans[2].Attributes, attribute.Int("http.response.status_code", 404))
+	assert.Len(spans[2].Events, 1)
+
+	metrics := metricdata.ResourceMetrics{}
+	assert.Nil(reader.Collect(context.Background(), &metrics))
+	assert.Len(metrics.ScopeMetrics, 1)
+
+	recorded := map[string]metricdata.Aggregation{}
+	for _, m := range metrics.ScopeMetrics[0].Metrics {
+		recorded[m.Name] = m.Data
+	}
+
+	duration := recorded["redash.client.request.duration"].(metricdata.Histogram[float64])
+	assert.Len(duration.DataPoints, 2)
+
+	errors := recorded["redash.client.request.errors"].(metricdata.Sum[int64])
+	assert.Len(errors.DataPoints, 1)
+	assert.Equal(int64(1), errors.DataPoints[0].Value)
+	status, _ := errors.DataPoints[0].Attributes.Value("http.response.status_code")
+	assert.Equal(int64(404), status.AsInt64())
+}
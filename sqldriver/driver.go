@@ -0,0 +1,252 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package sqldriver is a database/sql driver running queries through
+// Redash, so a data source can be queried under Redash's access control
+// without holding its credentials. Importing it registers the "redash"
+// driver, whose data source names are Redash URLs carrying an API key and
+// the id of the data source to query:
+//
+//	db, err := sql.Open("redash", "https://redash.acme.com/?api_key=...&data_source_id=3")
+//
+// A job_timeout parameter, e.g. "10m", bounds how long queries may run.
+// Rows are streamed as Redash sends them, unless its response lists the
+// columns after the rows, in which case the whole result is held in memory.
+// Queries are read-only ad-hoc queries: transactions are not supported, and
+// arguments are only accepted by name, filling the query's {{ name }}
+// placeholders:
+//
+//	rows, err := db.QueryContext(ctx, "SELECT * FROM events WHERE day = '{{ day }}'", sql.Named("day", "2022-03-01"))
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/snowplow-devops/redash-client-go/redash"
+)
+
+// DriverName is the name the driver is registered under
+const DriverName = "redash"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// ErrNotSupported is returned by the operations Redash has no equivalent
+// for, such as transactions
+var ErrNotSupported = errors.New("Not supported by Redash")
+
+// Driver implements driver.Driver and driver.DriverContext
+type Driver struct{}
+
+// Open returns a connection to the Redash data source named by the DSN
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector parses the DSN once for all the connections of a sql.DB
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	config, dataSourceID, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := redash.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Connector{Client: client, DataSourceID: dataSourceID, driver: d}, nil
+}
+
+// ParseDSN returns the client configuration and data source id of a DSN
+func ParseDSN(dsn string) (*redash.Config, redash.DataSourceID, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid DSN: %v", err)
+	}
+
+	params := u.Query()
+	config := &redash.Config{APIKey: params.Get("api_key")}
+	if config.APIKey == "" {
+		return nil, 0, fmt.Errorf("Invalid DSN: missing api_key")
+	}
+
+	id, err := strconv.Atoi(params.Get("data_source_id"))
+	if err != nil || id <= 0 {
+		return nil, 0, fmt.Errorf("Invalid DSN: missing or invalid data_source_id")
+	}
+
+	if timeout := params.Get("job_timeout"); timeout != "" {
+		config.JobTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Invalid DSN: invalid job_timeout: %v", err)
+		}
+	}
+
+	u.RawQuery = ""
+	u.Fragment = ""
+	config.RedashURI = u.String()
+
+	return config, redash.DataSourceID(id), nil
+}
+
+// Connector implements driver.Connector for an existing Client, so its
+// configuration, middlewares and logger apply to the queries:
+//
+//	db := sql.OpenDB(&sqldriver.Connector{Client: c, DataSourceID: 3})
+type Connector struct {
+	Client       *redash.Client
+	DataSourceID redash.DataSourceID
+
+	driver *Driver
+}
+
+// Connect returns a connection. Connections hold no state, as every query
+// is a separate Redash API call
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{client: c.Client, dataSourceID: c.DataSourceID}, nil
+}
+
+// Driver returns the redash Driver
+func (c *Connector) Driver() driver.Driver {
+	if c.driver == nil {
+		return &Driver{}
+	}
+
+	return c.driver
+}
+
+// conn implements driver.Conn, driver.QueryerContext, driver.ExecerContext
+// and driver.Pinger
+type conn struct {
+	client       *redash.Client
+	dataSourceID redash.DataSourceID
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, ErrNotSupported
+}
+
+// Ping checks the data source can be read with the API key
+func (c *conn) Ping(ctx context.Context) error {
+	_, err := c.client.GetDataSourceContext(ctx, c.dataSourceID)
+	return err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	parameters, err := namedParameters(args)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := c.client.StreamAdhocQueryContext(ctx, c.dataSourceID, query, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRows(reader)
+}
+
+// ExecContext runs the query and discards its result, as Redash does not
+// report affected rows
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.ResultNoRows, rows.Close()
+}
+
+// CheckNamedValue accepts every argument, as they are sent as parameters
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	return nil
+}
+
+// namedParameters turns named arguments into the query's parameters
+func namedParameters(args []driver.NamedValue) (map[string]interface{}, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	parameters := map[string]interface{}{}
+	for _, arg := range args {
+		if arg.Name == "" {
+			return nil, fmt.Errorf("Argument %d must be named with sql.Named to fill a {{ parameter }}", arg.Ordinal)
+		}
+
+		switch v := arg.Value.(type) {
+		case time.Time:
+			parameters[arg.Name] = v.Format("2006-01-02 15:04:05")
+		case []byte:
+			parameters[arg.Name] = string(v)
+		default:
+			parameters[arg.Name] = v
+		}
+	}
+
+	return parameters, nil
+}
+
+// stmt implements driver.Stmt. Nothing is prepared, the query is sent when
+// the statement is executed
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1 as parameters are named and not counted
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("Arguments must be named with sql.Named: %w", ErrNotSupported)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("Arguments must be named with sql.Named: %w", ErrNotSupported)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
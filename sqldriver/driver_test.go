@@ -0,0 +1,217 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow-devops/redash-client-go/redash"
+)
+
+const testDSN = "https://com.acme/?api_key=ApIkEyApIkEyApIkEyApIkEyApIkEy&data_source_id=3"
+
+const eventsResult = `{"query_result": {"id": 9, "data": {
+	"columns": [
+		{"name": "id", "type": "integer"},
+		{"name": "score", "type": "float"},
+		{"name": "active", "type": "boolean"},
+		{"name": "name", "type": "string"},
+		{"name": "day", "type": "date"},
+		{"name": "tags", "type": null}
+	],
+	"rows": [
+		{"id": 1, "score": 1.5, "active": true, "name": "one", "day": "2022-03-01", "tags": ["a", "b"]},
+		{"id": 2, "score": null, "active": false, "name": "two", "day": "2022-03-02", "tags": null}
+	]}}}`
+
+func TestParseDSN(t *testing.T) {
+	assert := assert.New(t)
+
+	config, dataSourceID, err := ParseDSN(testDSN + "&job_timeout=10m")
+	assert.Nil(err)
+	assert.Equal("https://com.acme/", config.RedashURI)
+	assert.Equal("ApIkEyApIkEyApIkEyApIkEyApIkEy", config.APIKey)
+	assert.Equal(10*time.Minute, config.JobTimeout)
+	assert.Equal(redash.DataSourceID(3), dataSourceID)
+
+	_, _, err = ParseDSN("https://com.acme/?data_source_id=3")
+	assert.EqualError(err, "Invalid DSN: missing api_key")
+
+	_, _, err = ParseDSN("https://com.acme/?api_key=ApIkEy&data_source_id=three")
+	assert.EqualError(err, "Invalid DSN: missing or invalid data_source_id")
+
+	_, _, err = ParseDSN(testDSN + "&job_timeout=soon")
+	assert.NotNil(err)
+}
+
+func TestQuery(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var payload map[string]interface{}
+	httpmock.RegisterResponder("POST", "https://com.acme/api/query_results",
+		func(req *http.Request) (*http.Response, error) {
+			body, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(body, &payload)
+			return httpmock.NewStringResponse(200, `{"job": {"id": "abc", "status": 1}}`), nil
+		})
+	httpmock.RegisterResponder("GET", "https://com.acme/api/jobs/abc",
+		httpmock.NewStringResponder(200, `{"job": {"id": "abc", "status": 3, "query_result_id": 9}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/query_results/9",
+		httpmock.NewStringResponder(200, eventsResult))
+
+	db, err := sql.Open(DriverName, testDSN)
+	assert.Nil(err)
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM events WHERE day >= '{{ day }}'", sql.Named("day", "2022-03-01"))
+	assert.Nil(err)
+	defer rows.Close()
+
+	assert.Equal(float64(3), payload["data_source_id"])
+	assert.Equal("SELECT * FROM events WHERE day >= '{{ day }}'", payload["query"])
+	assert.Equal(map[string]interface{}{"day": "2022-03-01"}, payload["parameters"])
+
+	columns, err := rows.Columns()
+	assert.Nil(err)
+	assert.Equal([]string{"id", "score", "active", "name", "day", "tags"}, columns)
+
+	types, err := rows.ColumnTypes()
+	assert.Nil(err)
+	assert.Equal("INTEGER", types[0].DatabaseTypeName())
+	assert.Equal("int64", types[0].ScanType().String())
+	assert.Equal("time.Time", types[4].ScanType().String())
+	nullable, ok := types[1].Nullable()
+	assert.True(nullable)
+	assert.True(ok)
+
+	var (
+		id     int64
+		score  sql.NullFloat64
+		active bool
+		name   string
+		day    time.Time
+		tags   []byte
+	)
+
+	assert.True(rows.Next())
+	assert.Nil(rows.Scan(&id, &score, &active, &name, &day, &tags))
+	assert.Equal(int64(1), id)
+	assert.Equal(sql.NullFloat64{Float64: 1.5, Valid: true}, score)
+	assert.True(active)
+	assert.Equal("one", name)
+	assert.Equal(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), day)
+	assert.Equal(`["a","b"]`, string(tags))
+
+	assert.True(rows.Next())
+	assert.Nil(rows.Scan(&id, &score, &active, &name, &day, &tags))
+	assert.Equal(int64(2), id)
+	assert.False(score.Valid)
+	assert.Nil(tags)
+
+	assert.False(rows.Next())
+	assert.Nil(rows.Err())
+}
+
+func TestQueryColumnsAfterRows(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://com.acme/api/query_results",
+		httpmock.NewStringResponder(200, `{"query_result": {"id": 9, "data": {
+			"rows": [{"n": 1}, {"n": 2}, {"n": 9007199254740993}],
+			"columns": [{"name": "n", "type": "integer"}]}}}`))
+
+	db, _ := sql.Open(DriverName, testDSN)
+	defer db.Close()
+
+	var values []int64
+	rows, err := db.Query("SELECT n FROM numbers")
+	assert.Nil(err)
+	defer rows.Close()
+	for rows.Next() {
+		var n int64
+		assert.Nil(rows.Scan(&n))
+		values = append(values, n)
+	}
+	assert.Nil(rows.Err())
+	assert.Equal([]int64{1, 2, 9007199254740993}, values)
+}
+
+func TestUnsupported(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	db, _ := sql.Open(DriverName, testDSN)
+	defer db.Close()
+
+	_, err := db.Query("SELECT * FROM events WHERE id = ?", 1)
+	assert.EqualError(err, "Argument 1 must be named with sql.Named to fill a {{ parameter }}")
+
+	_, err = db.Begin()
+	assert.True(errors.Is(err, ErrNotSupported))
+
+	assert.Equal(0, httpmock.GetTotalCallCount())
+}
+
+func TestPing(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/3",
+		httpmock.NewStringResponder(200, `{"id": 3, "name": "Events", "type": "pg", "options": {}}`))
+	httpmock.RegisterResponder("GET", "https://com.acme/api/data_sources/4",
+		httpmock.NewStringResponder(403, `{"message": "Forbidden"}`))
+
+	c, _ := redash.NewClient(&redash.Config{RedashURI: "https://com.acme/", APIKey: "ApIkEyApIkEyApIkEyApIkEyApIkEy"})
+
+	db := sql.OpenDB(&Connector{Client: c, DataSourceID: 3})
+	defer db.Close()
+	assert.Nil(db.Ping())
+
+	forbidden := sql.OpenDB(&Connector{Client: c, DataSourceID: 4})
+	defer forbidden.Close()
+	assert.EqualError(forbidden.Ping(), "HTTP Response: 403")
+}
+
+func TestExec(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://com.acme/api/query_results",
+		httpmock.NewStringResponder(200, eventsResult))
+
+	db, _ := sql.Open(DriverName, testDSN)
+	defer db.Close()
+
+	result, err := db.Exec("REFRESH MATERIALIZED VIEW events_daily")
+	assert.Nil(err)
+	_, err = result.RowsAffected()
+	assert.NotNil(err)
+}
@@ -0,0 +1,159 @@
+//
+// Copyright (c) 2020-2022 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/snowplow-devops/redash-client-go/redash"
+)
+
+// scanTypes are the Go types values of each Redash column type are given as
+var scanTypes = map[string]reflect.Type{
+	redash.ColumnInteger:  reflect.TypeOf(int64(0)),
+	redash.ColumnFloat:    reflect.TypeOf(float64(0)),
+	redash.ColumnBoolean:  reflect.TypeOf(false),
+	redash.ColumnString:   reflect.TypeOf(""),
+	redash.ColumnDate:     reflect.TypeOf(time.Time{}),
+	redash.ColumnDateTime: reflect.TypeOf(time.Time{}),
+}
+
+// rows implements driver.Rows and its column type extensions on top of a
+// streamed query result
+type rows struct {
+	reader  *redash.QueryResultReader
+	columns []redash.QueryResultColumn
+
+	// buffered holds the rows read ahead when Redash sent the columns
+	// after the rows
+	buffered []map[string]interface{}
+}
+
+// newRows reads the columns of the result before its rows are scanned. The
+// rows are streamed when Redash sends the columns first, as it does for
+// query results; should the columns come after the rows, every row is read
+// into memory to get to them, so the result is no longer streamed
+func newRows(reader *redash.QueryResultReader) (driver.Rows, error) {
+	r := &rows{reader: reader, columns: reader.Columns()}
+	if r.columns != nil {
+		return r, nil
+	}
+
+	for reader.Next() {
+		r.buffered = append(r.buffered, reader.Row())
+	}
+	if err := reader.Err(); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	r.columns = reader.Columns()
+
+	return r, nil
+}
+
+func (r *rows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		names[i] = column.Name
+	}
+
+	return names
+}
+
+func (r *rows) Close() error {
+	return r.reader.Close()
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	var row map[string]interface{}
+	if len(r.buffered) > 0 {
+		row, r.buffered = r.buffered[0], r.buffered[1:]
+	} else if r.reader.Next() {
+		row = r.reader.Row()
+	} else if err := r.reader.Err(); err != nil {
+		return err
+	} else {
+		return io.EOF
+	}
+
+	for i, column := range r.columns {
+		value, err := driverValue(row[column.Name], column.Type)
+		if err != nil {
+			return fmt.Errorf("Invalid value for column %s: %v", column.Name, err)
+		}
+		dest[i] = value
+	}
+
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName returns the Redash type of the column in upper
+// case, e.g. "INTEGER"
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.columns[index].Type)
+}
+
+// ColumnTypeScanType returns the Go type values of the column are given as
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	if scanType, ok := scanTypes[r.columns[index].Type]; ok {
+		return scanType
+	}
+
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+// ColumnTypeNullable reports every column as nullable, as Redash does not
+// say otherwise
+func (r *rows) ColumnTypeNullable(index int) (bool, bool) {
+	return true, true
+}
+
+// driverValue converts a value of the given column type to a driver.Value
+func driverValue(value interface{}, columnType string) (driver.Value, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		if columnType == redash.ColumnInteger {
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+		}
+		return v.Float64()
+	case float64:
+		if columnType == redash.ColumnInteger && v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return int64(v), nil
+		}
+		return v, nil
+	case string:
+		if columnType == redash.ColumnDate || columnType == redash.ColumnDateTime {
+			return redash.ParseDateTime(v)
+		}
+		return v, nil
+	case bool:
+		return v, nil
+	}
+
+	// arrays and objects, which some data sources return, are given as JSON
+	return json.Marshal(value)
+}